@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// APIKeyAuthenticator authenticates requests by a static API key sent in
+// a header or a query parameter.
+type APIKeyAuthenticator struct {
+	Header     string
+	QueryParam string
+	Consumers  *Consumers
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator looking up keys
+// among the given consumers.
+func NewAPIKeyAuthenticator(conf *config.APIKeyAuthConfig, consumers *Consumers) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		Header:     conf.Header,
+		QueryParam: conf.QueryParam,
+		Consumers:  consumers,
+	}
+}
+
+// Authenticate implements Authenticator. The API key is removed from the
+// request, so that it is not forwarded to the backend.
+func (a *APIKeyAuthenticator) Authenticate(req *http.Request) (string, error) {
+	key := req.Header.Get(a.Header)
+	if key == "" && a.QueryParam != "" {
+		key = req.URL.Query().Get(a.QueryParam)
+	}
+
+	req.Header.Del(a.Header)
+	if a.QueryParam != "" {
+		removeQueryParam(req.URL, a.QueryParam)
+	}
+
+	if key == "" {
+		return "", unauthenticated("missing API key")
+	}
+
+	consumer, ok := a.Consumers.ByAPIKey(key)
+	if !ok {
+		return "", unauthenticated("unknown API key")
+	}
+	return consumer, nil
+}
+
+// removeQueryParam removes all values of the query parameter from the URL,
+// leaving the rest of the query as it has been sent.
+func removeQueryParam(u *url.URL, name string) {
+	if u.RawQuery == "" {
+		return
+	}
+
+	parts := strings.Split(u.RawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		key := part
+		if i := strings.Index(part, "="); i >= 0 {
+			key = part[:i]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil && unescaped == name {
+			continue
+		}
+		kept = append(kept, part)
+	}
+	u.RawQuery = strings.Join(kept, "&")
+}
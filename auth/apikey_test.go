@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	consumers := &Consumers{byKeyHash: map[string]string{
+		strings.TrimPrefix(HashKey("secret-key"), KeyHashPrefix): "partner-a",
+	}}
+	a := &APIKeyAuthenticator{Header: "X-Api-Key", QueryParam: "api_key", Consumers: consumers}
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		consumer string
+		query    string
+	}{
+		{"header", "/orders?page=2", "secret-key", "partner-a", "page=2"},
+		{"query param", "/orders?page=2&api_key=secret-key&sort=desc", "", "partner-a", "page=2&sort=desc"},
+		{"escaped query param", "/orders?api%5Fkey=secret-key&q=a+b", "", "partner-a", "q=a+b"},
+		{"both", "/orders?api_key=other-key", "secret-key", "partner-a", ""},
+		{"unknown key", "/orders?api_key=other-key", "", "", ""},
+		{"missing key", "/orders?page=2", "", "", "page=2"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		if test.header != "" {
+			req.Header.Set("X-Api-Key", test.header)
+		}
+
+		consumer, err := a.Authenticate(req)
+		if test.consumer == "" && !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s :: expected the request to be unauthenticated, got %v", test.name, err)
+		}
+		if consumer != test.consumer {
+			t.Errorf("%s :: expected consumer '%s', got '%s'", test.name, test.consumer, consumer)
+		}
+
+		// The key must not reach the backend
+		if req.Header.Get("X-Api-Key") != "" {
+			t.Errorf("%s :: expected the API key header to be removed", test.name)
+		}
+		if req.URL.RawQuery != test.query {
+			t.Errorf("%s :: expected query '%s', got '%s'", test.name, test.query, req.URL.RawQuery)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// ErrUnauthenticated is returned by authenticators when a request does not
+// carry valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrBodyTooLarge is returned by authenticators reading the request body
+// when it is larger than they are allowed to read.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// Authenticator authenticates incoming requests. Authenticate returns the
+// name of the consumer the request has been sent by, or an error wrapping
+// ErrUnauthenticated if the request does not carry valid credentials. It
+// may set headers on the request to pass information on to the backend.
+type Authenticator interface {
+	Authenticate(req *http.Request) (string, error)
+}
+
+// Challenger is implemented by authenticators whose scheme defines a
+// WWW-Authenticate challenge to send along with rejected requests.
+type Challenger interface {
+	Challenge() string
+}
+
+// New creates the Authenticator described by the (already optimised) auth
+// config. Consumers is the registry of consumers allowed to authenticate.
+func New(conf *config.AuthConfig, consumers *Consumers) (Authenticator, error) {
+	switch conf.Type {
+	case config.AuthTypeAPIKey:
+		if consumers == nil {
+			return nil, errors.New("API key authentication requires a consumers file")
+		}
+		return NewAPIKeyAuthenticator(&conf.APIKey, consumers), nil
+
+	case config.AuthTypeJWT:
+		return NewJWTAuthenticator(&conf.JWT)
+
+	case config.AuthTypeHMAC:
+		if consumers == nil {
+			return nil, errors.New("HMAC authentication requires a consumers file")
+		}
+		return NewHMACAuthenticator(&conf.HMAC, consumers), nil
+
+	default:
+		return nil, fmt.Errorf("unknown auth type '%s'", conf.Type)
+	}
+}
+
+// unauthenticated wraps ErrUnauthenticated with the reason a request has
+// been rejected.
+func unauthenticated(reason string) error {
+	return fmt.Errorf("%w: %s", ErrUnauthenticated, reason)
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// KeyHashPrefix prefixes the hashed API keys in the consumers file. It
+// leaves room to introduce other hashing schemes in the future.
+const KeyHashPrefix = "sha256:"
+
+// Consumer is a client of the gateway. Its API keys are never stored in
+// plain text, only as '<KeyHashPrefix><hex encoded sha256 of the key>'.
+type Consumer struct {
+	Name     string    `yaml:"name"`
+	APIKeys  []string  `yaml:"api_keys"`
+	HMACKeys []HMACKey `yaml:"hmac_keys"`
+}
+
+// HMACKey is a token and secret pair used to sign requests. Unlike API keys
+// the secret has to be stored in plain text, since the gateway needs it to
+// compute the signatures. The token identifies the key and is sent along
+// with every request, the secret never is.
+type HMACKey struct {
+	Token  string `yaml:"token"`
+	Secret string `yaml:"secret"`
+}
+
+// Consumers is the registry of all consumers allowed to authenticate with
+// the gateway, loaded from the consumers file.
+type Consumers struct {
+	Consumers []Consumer `yaml:"consumers"`
+
+	// consumer names indexed by the hashes of their keys
+	byKeyHash map[string]string
+
+	// consumer names and secrets indexed by HMAC tokens
+	byToken map[string]hmacOwner
+}
+
+type hmacOwner struct {
+	consumer string
+	secret   []byte
+}
+
+// LoadConsumers reads and parses the consumers file.
+//
+//	consumers:
+//	- name: "partner-a"
+//	  api_keys:
+//	  - "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+//	  hmac_keys:
+//	  - token: "pa-2021-01"
+//	    secret: "8b1c2f0e6d..."
+func LoadConsumers(path string) (*Consumers, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var consumers Consumers
+	if err := yaml.Unmarshal(content, &consumers); err != nil {
+		return nil, err
+	}
+
+	consumers.byKeyHash = make(map[string]string)
+	consumers.byToken = make(map[string]hmacOwner)
+	for _, consumer := range consumers.Consumers {
+		if consumer.Name == "" {
+			return nil, fmt.Errorf("consumer without a name in '%s'", path)
+		}
+
+		for _, key := range consumer.APIKeys {
+			hash := strings.ToLower(strings.TrimPrefix(key, KeyHashPrefix))
+			if !strings.HasPrefix(key, KeyHashPrefix) || len(hash) != 2*sha256.Size {
+				return nil, fmt.Errorf("invalid API key hash for consumer '%s', expected '%s<hex encoded sha256 of the key>'", consumer.Name, KeyHashPrefix)
+			}
+			if owner, ok := consumers.byKeyHash[hash]; ok {
+				return nil, fmt.Errorf("API key of consumer '%s' is also used by consumer '%s'", consumer.Name, owner)
+			}
+			consumers.byKeyHash[hash] = consumer.Name
+		}
+
+		for _, key := range consumer.HMACKeys {
+			if key.Token == "" || key.Secret == "" {
+				return nil, fmt.Errorf("HMAC key of consumer '%s' requires both a token and a secret", consumer.Name)
+			}
+			if owner, ok := consumers.byToken[key.Token]; ok {
+				return nil, fmt.Errorf("HMAC token '%s' of consumer '%s' is also used by consumer '%s'", key.Token, consumer.Name, owner.consumer)
+			}
+			consumers.byToken[key.Token] = hmacOwner{consumer: consumer.Name, secret: []byte(key.Secret)}
+		}
+	}
+
+	return &consumers, nil
+}
+
+// HashKey hashes an API key the way it is stored in the consumers file.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return KeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// ByAPIKey returns the name of the consumer owning the API key, if any.
+func (c *Consumers) ByAPIKey(key string) (string, bool) {
+	name, ok := c.byKeyHash[strings.TrimPrefix(HashKey(key), KeyHashPrefix)]
+	return name, ok
+}
+
+// ByHMACToken returns the name of the consumer owning the HMAC token and the
+// secret paired with it, if any.
+func (c *Consumers) ByHMACToken(token string) (string, []byte, bool) {
+	owner, ok := c.byToken[token]
+	return owner.consumer, owner.secret, ok
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// HMACAuthenticator authenticates requests signed with a consumer's token
+// and secret pair. See config.HMACAuthConfig for the signature scheme.
+type HMACAuthenticator struct {
+	TokenHeader     string
+	TimestampHeader string
+	SignatureHeader string
+	ClockSkew       time.Duration
+	MaxBodySize     int64
+	Consumers       *Consumers
+}
+
+// NewHMACAuthenticator creates an HMACAuthenticator looking up tokens among
+// the given consumers.
+func NewHMACAuthenticator(conf *config.HMACAuthConfig, consumers *Consumers) *HMACAuthenticator {
+	return &HMACAuthenticator{
+		TokenHeader:     conf.TokenHeader,
+		TimestampHeader: conf.TimestampHeader,
+		SignatureHeader: conf.SignatureHeader,
+		ClockSkew:       conf.ClockSkewDuration,
+		MaxBodySize:     conf.MaxBodySizeBytes,
+		Consumers:       consumers,
+	}
+}
+
+// Authenticate implements Authenticator. The request body is read to be
+// digested and replaced with an in-memory copy of it. Bodies of more than
+// MaxBodySize bytes are not read any further and rejected with
+// ErrBodyTooLarge.
+func (a *HMACAuthenticator) Authenticate(req *http.Request) (string, error) {
+	token := req.Header.Get(a.TokenHeader)
+	timestamp := req.Header.Get(a.TimestampHeader)
+	signature := req.Header.Get(a.SignatureHeader)
+	if token == "" || timestamp == "" || signature == "" {
+		return "", unauthenticated("missing HMAC token, timestamp or signature")
+	}
+
+	consumer, secret, ok := a.Consumers.ByHMACToken(token)
+	if !ok {
+		return "", unauthenticated("unknown HMAC token")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", unauthenticated("malformed timestamp")
+	}
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > a.ClockSkew {
+		return "", unauthenticated("timestamp outside of the allowed clock skew")
+	}
+
+	given, err := hex.DecodeString(strings.ToLower(signature))
+	if err != nil {
+		return "", unauthenticated("malformed signature")
+	}
+
+	digest, err := bodyDigest(req, a.MaxBodySize)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + digest))
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return "", unauthenticated("invalid signature")
+	}
+	return consumer, nil
+}
+
+// bodyDigest returns the hex encoded SHA-256 of the request body, leaving
+// the body readable for the handlers further down the chain.
+func bodyDigest(req *http.Request, limit int64) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		if req.ContentLength > limit {
+			return "", ErrBodyTooLarge
+		}
+
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(req.Body, limit+1))
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > limit {
+			return "", ErrBodyTooLarge
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signRequest returns the signature of a request as computed by clients.
+func signRequest(secret, method, uri, timestamp, body string) string {
+	digest := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + hex.EncodeToString(digest[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	a := &HMACAuthenticator{
+		TokenHeader:     "X-Api-Token",
+		TimestampHeader: "X-Timestamp",
+		SignatureHeader: "X-Signature",
+		ClockSkew:       5 * time.Minute,
+		MaxBodySize:     32,
+		Consumers: &Consumers{byToken: map[string]hmacOwner{
+			"pa-2021-01": {consumer: "partner-a", secret: []byte("secret")},
+		}},
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	ahead := strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10)
+	body := `{"item":42}`
+	signature := signRequest("secret", "POST", "/orders?expand=items", now, body)
+
+	tests := []struct {
+		name      string
+		token     string
+		uri       string
+		timestamp string
+		body      string
+		signature string
+		consumer  string
+	}{
+		{"valid", "pa-2021-01", "/orders?expand=items", now, body, signature, "partner-a"},
+		{"uppercase signature", "pa-2021-01", "/orders?expand=items", now, body, strings.ToUpper(signature), "partner-a"},
+		{"tampered body", "pa-2021-01", "/orders?expand=items", now, `{"item":43}`, signature, ""},
+		{"tampered path", "pa-2021-01", "/orders/42?expand=items", now, body, signature, ""},
+		{"tampered query", "pa-2021-01", "/orders?expand=none", now, body, signature, ""},
+		{"stale timestamp", "pa-2021-01", "/orders?expand=items", stale, body, signRequest("secret", "POST", "/orders?expand=items", stale, body), ""},
+		{"timestamp ahead", "pa-2021-01", "/orders?expand=items", ahead, body, signRequest("secret", "POST", "/orders?expand=items", ahead, body), ""},
+		{"unknown token", "pb-2021-01", "/orders?expand=items", now, body, signature, ""},
+		{"missing signature", "pa-2021-01", "/orders?expand=items", now, body, "", ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.uri, strings.NewReader(test.body))
+		req.Header.Set("X-Api-Token", test.token)
+		req.Header.Set("X-Timestamp", test.timestamp)
+		req.Header.Set("X-Signature", test.signature)
+
+		consumer, err := a.Authenticate(req)
+		if test.consumer == "" && !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s :: expected the request to be unauthenticated, got %v", test.name, err)
+		}
+		if consumer != test.consumer {
+			t.Errorf("%s :: expected consumer '%s', got '%s'", test.name, test.consumer, consumer)
+		}
+
+		// The body must remain readable for the backend
+		if err == nil {
+			if content, _ := ioutil.ReadAll(req.Body); string(content) != test.body {
+				t.Errorf("%s :: expected the body '%s' to be left readable, got '%s'", test.name, test.body, content)
+			}
+		}
+	}
+}
+
+func TestHMACBodyTooLarge(t *testing.T) {
+	a := &HMACAuthenticator{
+		TokenHeader:     "X-Api-Token",
+		TimestampHeader: "X-Timestamp",
+		SignatureHeader: "X-Signature",
+		ClockSkew:       5 * time.Minute,
+		MaxBodySize:     8,
+		Consumers: &Consumers{byToken: map[string]hmacOwner{
+			"pa-2021-01": {consumer: "partner-a", secret: []byte("secret")},
+		}},
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		err           error
+	}{
+		{"at the limit", "12345678", 8, nil},
+		{"declared too large", "123456789", 9, ErrBodyTooLarge},
+		{"streamed too large", "123456789", -1, ErrBodyTooLarge},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(test.body))
+		req.ContentLength = test.contentLength
+		req.Header.Set("X-Api-Token", "pa-2021-01")
+		req.Header.Set("X-Timestamp", now)
+		req.Header.Set("X-Signature", signRequest("secret", "POST", "/orders", now, test.body))
+
+		if _, err := a.Authenticate(req); err != test.err {
+			t.Errorf("%s :: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
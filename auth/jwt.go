@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// JWTAuthenticator authenticates requests by a JWT sent as a bearer token
+// in the Authorization header. See config.JWTAuthConfig for the checks it
+// performs.
+type JWTAuthenticator struct {
+	Config *config.JWTAuthConfig
+
+	secret    *verificationKey
+	keyFiles  []*keyFile
+	algorithm map[string]bool
+}
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// NewJWTAuthenticator creates a JWTAuthenticator from the (already
+// optimised) JWT config, loading the key files it references.
+func NewJWTAuthenticator(conf *config.JWTAuthConfig) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{
+		Config:    conf,
+		algorithm: make(map[string]bool),
+	}
+
+	for _, alg := range conf.Algorithms {
+		a.algorithm[alg] = true
+	}
+
+	if conf.Secret != "" {
+		a.secret = &verificationKey{Key: []byte(conf.Secret)}
+	}
+
+	if conf.PublicKeyFilePath != "" {
+		kf, err := newKeyFile(conf.PublicKeyFilePath, parsePEM)
+		if err != nil {
+			return nil, err
+		}
+		a.keyFiles = append(a.keyFiles, kf)
+	}
+
+	if conf.JWKSFilePath != "" {
+		kf, err := newKeyFile(conf.JWKSFilePath, parseJWKS)
+		if err != nil {
+			return nil, err
+		}
+		a.keyFiles = append(a.keyFiles, kf)
+	}
+
+	return a, nil
+}
+
+// Challenge implements Challenger.
+func (a *JWTAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+// Authenticate implements Authenticator. The claims listed in the forward
+// claims are set as headers on the request, after removing any values
+// the client may have sent for them.
+func (a *JWTAuthenticator) Authenticate(req *http.Request) (string, error) {
+	for _, header := range a.Config.ForwardClaims {
+		req.Header.Del(header)
+	}
+
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return "", unauthenticated("missing bearer token")
+	}
+
+	claims, err := a.verify(strings.TrimSpace(authorization[7:]))
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.checkClaims(claims, time.Now()); err != nil {
+		return "", err
+	}
+
+	consumer, ok := claimString(claims[a.Config.ConsumerClaim])
+	if !ok || consumer == "" {
+		return "", unauthenticated(fmt.Sprintf("missing '%s' claim", a.Config.ConsumerClaim))
+	}
+
+	for claim, header := range a.Config.ForwardClaims {
+		if value, ok := claimString(claims[claim]); ok {
+			req.Header.Set(header, value)
+		}
+	}
+
+	return consumer, nil
+}
+
+// verify checks the signature of the token and returns its claims.
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, unauthenticated("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJSONSegment(parts[0], &header); err != nil {
+		return nil, unauthenticated("malformed token header")
+	}
+	if !a.algorithm[header.Alg] {
+		return nil, unauthenticated(fmt.Sprintf("algorithm '%s' not allowed", header.Alg))
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, unauthenticated("malformed token signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range a.keys() {
+		if header.Kid != "" && key.ID != "" && key.ID != header.Kid {
+			continue
+		}
+		if key.supports(header.Alg) && verifySignature(header.Alg, key.Key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, unauthenticated("invalid token signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJSONSegment(parts[1], &claims); err != nil {
+		return nil, unauthenticated("malformed token claims")
+	}
+	return claims, nil
+}
+
+// keys returns all keys the authenticator verifies tokens with. Key files
+// failing to reload keep their previous keys.
+func (a *JWTAuthenticator) keys() []*verificationKey {
+	var keys []*verificationKey
+	if a.secret != nil {
+		keys = append(keys, a.secret)
+	}
+	for _, kf := range a.keyFiles {
+		fileKeys, _ := kf.Keys()
+		keys = append(keys, fileKeys...)
+	}
+	return keys
+}
+
+// checkClaims validates the registered claims and the required claims.
+func (a *JWTAuthenticator) checkClaims(claims map[string]interface{}, now time.Time) error {
+	leeway := a.Config.LeewayDuration
+
+	if exp, ok := claimTime(claims["exp"]); ok && now.After(exp.Add(leeway)) {
+		return unauthenticated("token expired")
+	} else if !ok && claims["exp"] != nil {
+		return unauthenticated("malformed 'exp' claim")
+	}
+
+	if nbf, ok := claimTime(claims["nbf"]); ok && now.Before(nbf.Add(-leeway)) {
+		return unauthenticated("token not valid yet")
+	} else if !ok && claims["nbf"] != nil {
+		return unauthenticated("malformed 'nbf' claim")
+	}
+
+	if a.Config.Issuer != "" {
+		if iss, _ := claimString(claims["iss"]); iss != a.Config.Issuer {
+			return unauthenticated("unexpected issuer")
+		}
+	}
+
+	if a.Config.Audience != "" && !claimContains(claims["aud"], a.Config.Audience, false) {
+		return unauthenticated("unexpected audience")
+	}
+
+	for claim, value := range a.Config.RequiredClaims {
+		if !claimContains(claims[claim], value, true) {
+			return unauthenticated(fmt.Sprintf("claim '%s' does not match", claim))
+		}
+	}
+
+	return nil
+}
+
+func verifySignature(alg string, key interface{}, signed []byte, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case config.JWTAlgHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+
+	case config.JWTAlgRS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+
+	case config.JWTAlgES256:
+		// ES256 signatures are the concatenation of r and s
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), digest[:], r, s)
+
+	default:
+		return false
+	}
+}
+
+func decodeJSONSegment(segment string, v interface{}) error {
+	content, err := decodeSegment(segment)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// claimTime converts a NumericDate claim into a time.
+func claimTime(claim interface{}) (time.Time, bool) {
+	number, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// claimString converts a claim into a string. Arrays are joined with
+// commas, objects are not converted.
+func claimString(claim interface{}) (string, bool) {
+	switch v := claim.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return fmt.Sprint(v), true
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if value, ok := claimString(item); ok {
+				values = append(values, value)
+			}
+		}
+		return strings.Join(values, ","), true
+	default:
+		return "", false
+	}
+}
+
+// claimContains reports whether the claim equals the value or, for array
+// claims, contains it. If spaceDelimited is true, string claims are also
+// treated as space delimited lists, as is customary for 'scope'.
+func claimContains(claim interface{}, value string, spaceDelimited bool) bool {
+	switch v := claim.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := claimString(item); ok && s == value {
+				return true
+			}
+		}
+		return false
+
+	case string:
+		if v == value {
+			return true
+		}
+		if spaceDelimited {
+			for _, item := range strings.Fields(v) {
+				if item == value {
+					return true
+				}
+			}
+		}
+		return false
+
+	default:
+		s, ok := claimString(v)
+		return ok && s == value
+	}
+}
@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// signToken builds a JWT signed with the key, a []byte secret, an
+// *rsa.PrivateKey or an *ecdsa.PrivateKey, under the given algorithm.
+func signToken(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			// r and s are left padded to 32 bytes each
+			signature = make([]byte, 64)
+			rBytes, sBytes := r.Bytes(), s.Bytes()
+			copy(signature[32-len(rBytes):32], rBytes)
+			copy(signature[64-len(sBytes):], sBytes)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writePublicKey writes the PEM encoded public key to the file at path and
+// returns its content.
+func writePublicKey(t *testing.T, path string, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hodor-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func authenticate(a *JWTAuthenticator, token string) (string, error) {
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return a.Authenticate(req)
+}
+
+func TestJWTSignatures(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherECKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := tempDir(t)
+	rsaPEM := writePublicKey(t, filepath.Join(dir, "rsa.pem"), &rsaKey.PublicKey)
+	writePublicKey(t, filepath.Join(dir, "ec.pem"), &ecKey.PublicKey)
+
+	secret := []byte("shared-secret")
+	claims := map[string]interface{}{"sub": "partner-a"}
+
+	tests := []struct {
+		name  string
+		conf  config.JWTAuthConfig
+		token string
+		valid bool
+	}{
+		{
+			"HS256",
+			config.JWTAuthConfig{Algorithms: []string{"HS256"}, Secret: string(secret)},
+			signToken(t, "HS256", secret, claims),
+			true,
+		},
+		{
+			"HS256 with another secret",
+			config.JWTAuthConfig{Algorithms: []string{"HS256"}, Secret: string(secret)},
+			signToken(t, "HS256", []byte("other-secret"), claims),
+			false,
+		},
+		{
+			"RS256",
+			config.JWTAuthConfig{Algorithms: []string{"RS256", "ES256"}, PublicKeyFilePath: filepath.Join(dir, "rsa.pem")},
+			signToken(t, "RS256", rsaKey, claims),
+			true,
+		},
+		{
+			"ES256",
+			config.JWTAuthConfig{Algorithms: []string{"RS256", "ES256"}, PublicKeyFilePath: filepath.Join(dir, "ec.pem")},
+			signToken(t, "ES256", ecKey, claims),
+			true,
+		},
+		{
+			"ES256 with another key",
+			config.JWTAuthConfig{Algorithms: []string{"RS256", "ES256"}, PublicKeyFilePath: filepath.Join(dir, "ec.pem")},
+			signToken(t, "ES256", otherECKey, claims),
+			false,
+		},
+		{
+			"algorithm not allowed",
+			config.JWTAuthConfig{Algorithms: []string{"RS256"}, Secret: string(secret), PublicKeyFilePath: filepath.Join(dir, "rsa.pem")},
+			signToken(t, "HS256", secret, claims),
+			false,
+		},
+		{
+			"algorithm not matching the key type",
+			config.JWTAuthConfig{Algorithms: []string{"RS256", "ES256"}, PublicKeyFilePath: filepath.Join(dir, "rsa.pem")},
+			signToken(t, "ES256", ecKey, claims),
+			false,
+		},
+		{
+			// The public key is known to anyone, it must never
+			// be accepted as an HMAC secret
+			"HS256 signed with the RSA public key",
+			config.JWTAuthConfig{Algorithms: []string{"HS256", "RS256"}, PublicKeyFilePath: filepath.Join(dir, "rsa.pem")},
+			signToken(t, "HS256", rsaPEM, claims),
+			false,
+		},
+		{
+			"none",
+			config.JWTAuthConfig{Algorithms: []string{"HS256"}, Secret: string(secret)},
+			base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"partner-a"}`)) + ".",
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		test.conf.ConsumerClaim = "sub"
+		a, err := NewJWTAuthenticator(&test.conf)
+		if err != nil {
+			t.Fatalf("%s :: %s", test.name, err)
+		}
+
+		consumer, err := authenticate(a, test.token)
+		if test.valid && (err != nil || consumer != "partner-a") {
+			t.Errorf("%s :: expected consumer 'partner-a', got '%s' and %v", test.name, consumer, err)
+		}
+		if !test.valid && !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s :: expected the token to be rejected, got consumer '%s' and %v", test.name, consumer, err)
+		}
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	a := &JWTAuthenticator{Config: &config.JWTAuthConfig{
+		Issuer:         "https://issuer.example.com",
+		Audience:       "orders",
+		LeewayDuration: 30 * time.Second,
+		RequiredClaims: map[string]string{"scope": "orders:read"},
+	}}
+	now := time.Unix(1600000000, 0)
+	valid := func(changes map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"iss":   "https://issuer.example.com",
+			"aud":   []interface{}{"billing", "orders"},
+			"scope": "profile orders:read",
+		}
+		for claim, value := range changes {
+			if value == nil {
+				delete(claims, claim)
+			} else {
+				claims[claim] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"valid", valid(nil), true},
+		{"expired within the leeway", valid(map[string]interface{}{"exp": json.Number("1599999980")}), true},
+		{"expired beyond the leeway", valid(map[string]interface{}{"exp": json.Number("1599999960")}), false},
+		{"not valid yet within the leeway", valid(map[string]interface{}{"nbf": json.Number("1600000020")}), true},
+		{"not valid yet beyond the leeway", valid(map[string]interface{}{"nbf": json.Number("1600000040")}), false},
+		{"malformed exp", valid(map[string]interface{}{"exp": "tomorrow"}), false},
+		{"unexpected issuer", valid(map[string]interface{}{"iss": "https://other.example.com"}), false},
+		{"missing issuer", valid(map[string]interface{}{"iss": nil}), false},
+		{"single audience", valid(map[string]interface{}{"aud": "orders"}), true},
+		{"unexpected audience", valid(map[string]interface{}{"aud": "billing"}), false},
+		{"required claim in an array", valid(map[string]interface{}{"scope": []interface{}{"orders:read"}}), true},
+		{"required claim not matching", valid(map[string]interface{}{"scope": "orders:readwrite"}), false},
+		{"required claim missing", valid(map[string]interface{}{"scope": nil}), false},
+	}
+
+	for _, test := range tests {
+		err := a.checkClaims(test.claims, now)
+		if test.valid && err != nil {
+			t.Errorf("%s :: expected the claims to be valid, got %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s :: expected the claims to be rejected, got %v", test.name, err)
+		}
+	}
+}
+
+func TestJWTForwardClaims(t *testing.T) {
+	secret := []byte("shared-secret")
+	a, err := NewJWTAuthenticator(&config.JWTAuthConfig{
+		Algorithms:    []string{"HS256"},
+		Secret:        string(secret),
+		ConsumerClaim: "sub",
+		ForwardClaims: map[string]string{"tenant": "X-Tenant", "roles": "X-Roles", "org": "X-Org"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, "HS256", secret, map[string]interface{}{
+		"sub":    "partner-a",
+		"tenant": "acme",
+		"roles":  []string{"reader", "writer"},
+	}))
+	req.Header.Set("X-Tenant", "spoofed")
+	req.Header.Set("X-Org", "spoofed")
+
+	if _, err := a.Authenticate(req); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"X-Tenant": "acme", "X-Roles": "reader,writer", "X-Org": ""}
+	for header, value := range want {
+		if got := req.Header.Get(header); got != value {
+			t.Errorf("expected header %s to be '%s', got '%s'", header, value, got)
+		}
+	}
+}
+
+func TestJWTKeyFileReload(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(tempDir(t), "key.pem")
+	writePublicKey(t, path, &oldKey.PublicKey)
+
+	a, err := NewJWTAuthenticator(&config.JWTAuthConfig{
+		Algorithms:        []string{"ES256"},
+		PublicKeyFilePath: path,
+		ConsumerClaim:     "sub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken := signToken(t, "ES256", oldKey, map[string]interface{}{"sub": "partner-a"})
+	newToken := signToken(t, "ES256", newKey, map[string]interface{}{"sub": "partner-a"})
+
+	// rotate replaces the content of the key file and makes it due for a check
+	rotate := func(content func()) {
+		content()
+		modTime := time.Now().Add(time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		a.keyFiles[0].lastChecked = time.Time{}
+	}
+
+	writePublicKey(t, path, &newKey.PublicKey)
+	if _, err := authenticate(a, newToken); err == nil {
+		t.Errorf("expected the key file not to be reloaded before the check interval")
+	}
+
+	rotate(func() {})
+	if _, err := authenticate(a, newToken); err != nil {
+		t.Errorf("expected the rotated key to be loaded, got %v", err)
+	}
+	if _, err := authenticate(a, oldToken); err == nil {
+		t.Errorf("expected the previous key to be dropped")
+	}
+
+	rotate(func() {
+		if err := ioutil.WriteFile(path, []byte("not a key"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := authenticate(a, newToken); err != nil {
+		t.Errorf("expected the keys to be kept when the file is invalid, got %v", err)
+	}
+}
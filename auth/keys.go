@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyFileCheckInterval is the minimum interval at which key files are
+// checked for changes.
+const keyFileCheckInterval = 10 * time.Second
+
+// verificationKey is a key JWTs can be verified with. Key holds either
+// a []byte secret, an *rsa.PublicKey or an *ecdsa.PublicKey.
+type verificationKey struct {
+	ID  string
+	Alg string
+	Key interface{}
+}
+
+// supports reports whether the key can verify signatures made with alg.
+// The kind of key is checked in addition to its declared algorithm, so
+// that a public key can never be misused as an HMAC secret.
+func (k *verificationKey) supports(alg string) bool {
+	if k.Alg != "" && k.Alg != alg {
+		return false
+	}
+
+	switch key := k.Key.(type) {
+	case []byte:
+		return alg == "HS256"
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && key.Curve == elliptic.P256()
+	default:
+		return false
+	}
+}
+
+// keyFile is a file holding verification keys, either a PEM encoded public
+// key or a JWKS. It is reloaded whenever its modification time changes.
+type keyFile struct {
+	path  string
+	parse func([]byte) ([]*verificationKey, error)
+
+	mu          sync.RWMutex
+	keys        []*verificationKey
+	modTime     time.Time
+	lastChecked time.Time
+}
+
+func newKeyFile(path string, parse func([]byte) ([]*verificationKey, error)) (*keyFile, error) {
+	kf := &keyFile{path: path, parse: parse}
+	if err := kf.reload(); err != nil {
+		return nil, err
+	}
+	return kf, nil
+}
+
+// Keys returns the keys currently held by the file, reloading them first
+// if the file has changed. If reloading fails, the previous keys are kept.
+func (kf *keyFile) Keys() ([]*verificationKey, error) {
+	kf.mu.RLock()
+	keys, due := kf.keys, time.Since(kf.lastChecked) >= keyFileCheckInterval
+	kf.mu.RUnlock()
+
+	if !due {
+		return keys, nil
+	}
+
+	if err := kf.reload(); err != nil {
+		return keys, err
+	}
+
+	kf.mu.RLock()
+	defer kf.mu.RUnlock()
+	return kf.keys, nil
+}
+
+func (kf *keyFile) reload() error {
+	kf.mu.Lock()
+	defer kf.mu.Unlock()
+
+	kf.lastChecked = time.Now()
+
+	info, err := os.Stat(kf.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(kf.modTime) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(kf.path)
+	if err != nil {
+		return err
+	}
+
+	keys, err := kf.parse(content)
+	if err != nil {
+		return fmt.Errorf("error while parsing key file '%s': %w", kf.path, err)
+	}
+
+	kf.keys = keys
+	kf.modTime = info.ModTime()
+	return nil
+}
+
+// parsePEM parses a PEM encoded RSA or EC public key or certificate.
+func parsePEM(content []byte) ([]*verificationKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return []*verificationKey{{Key: key}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// jwk is a single JSON Web Key as defined by RFC 7517. Only the members
+// needed for RSA, P-256 EC and symmetric keys are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS parses a JSON Web Key Set. Keys meant for encryption and keys
+// of unsupported types are skipped.
+func parseJWKS(content []byte) ([]*verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []*verificationKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key '%s': %w", k.Kid, err)
+		}
+		if key != nil {
+			keys = append(keys, &verificationKey{ID: k.Kid, Alg: k.Alg, Key: key})
+		}
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on curve P-256")
+		}
+		return key, nil
+
+	case "oct":
+		return decodeSegment(k.K)
+
+	default:
+		return nil, nil
+	}
+}
+
+// decodeSegment decodes base64url data, with or without padding.
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -1,14 +1,17 @@
 package config
 
 import (
-	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -24,16 +27,69 @@ const (
 	TimeNil         = time.Duration(0) * time.Second
 	CFLevelGateway  = "gateway"
 	CFLevelEndpoint = "endpoint"
+
+	// Log levels
+	LogLevelDebug   = "DEBUG"
+	LogLevelInfo    = "INFO"
+	LogLevelWarning = "WARNING"
+	LogLevelError   = "ERROR"
+
+	// Access log formats
+	AccessLogJSON     = "JSON"
+	AccessLogCombined = "COMBINED"
+	AccessLogTemplate = "TEMPLATE"
+
+	// Log outputs
+	LogOutputStdio = "STDIO"
+	LogOutputFile  = "FILE"
+	LogOutputKafka = "KAFKA"
+	LogOutputELK   = "ELK"
+
+	// Rate limiter stores
+	RLStoreMemory = "MEMORY"
+	RLStoreRedis  = "REDIS"
+
+	// Middleware failure policies
+	MWFailOpen   = "FAIL_OPEN"
+	MWFailClosed = "FAIL_CLOSED"
+
+	// Authentication types
+	AuthTypeNone   = "NONE"
+	AuthTypeAPIKey = "API_KEY"
+	AuthTypeJWT    = "JWT"
+	AuthTypeHMAC   = "HMAC"
+
+	// JWT signing algorithms
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+
+	// Rate limiter keys
+	RLKeyAll      = "all"
+	RLKeyIP       = "ip"
+	RLKeyConsumer = "consumer"
+	RLKeyHeader   = "header"
+	RLKeyParam    = "param"
 )
 
 var portRegex = regexp.MustCompile(`:[0-9]+$`)
-var durationRegex = regexp.MustCompile(`([0-9]+)(S|s|M|m|H|h){1}`)
+var sizeRegex = regexp.MustCompile(`(?i)^([0-9]+)(KB|MB|GB)$`)
+var durationRegex = regexp.MustCompile(`^([0-9]+)(S|s|M|m|H|h)$`)
+var keyByRegex = regexp.MustCompile(`(?i)^(all|ip|consumer|header:[A-Za-z0-9_-]+|param:[A-Za-z0-9_]+)$`)
+var headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
 var methodsRegex = regexp.MustCompile(`(?i)(^GET$|^PUT$|^POST$|^DELETE$|^OPTIONS$|^PATCH$|^HEAD$)`)
 
-// Conf is a globally accessible singleton instance of type Config.
-// It is used by all modules that need to utilise the configuration.
-var Conf Config
-var once sync.Once
+// Option customises how Load and Parse validate a config.
+type Option func(*Config)
+
+// WithMiddlewareLookup makes validation reject native middleware referenced
+// by a name the lookup reports as not registered. Names are not checked
+// without it. Ex: config.Load(path, config.WithMiddlewareLookup(gateway.MiddlewareRegistered))
+func WithMiddlewareLookup(registered func(name string) bool) Option {
+	return func(c *Config) {
+		c.middlewareRegistered = registered
+	}
+}
 
 // Config encapsulates the entire application wide configuration.
 // Currently it serves the only purpose of wrapping the gateway
@@ -42,10 +98,20 @@ var once sync.Once
 // outside the scope of the gateway's configuration
 // TODO: Update the configuration documentation
 type Config struct {
-	Initialised      bool
-	ValidationFailed bool
-	ConfigFilePath   string
-	Gateway          GatewayConfig `yaml:"gateway"`
+	Initialised    bool
+	ConfigFilePath string
+	Gateway        GatewayConfig `yaml:"gateway"`
+
+	// Errors lists the rules the config breaks, found by validation
+	Errors ValidationErrors `yaml:"-"`
+
+	// Files the config has been read from, to locate the errors in,
+	// and the gateway file among them
+	sources     []source
+	gatewayFile string
+
+	// Reports whether a native middleware is registered under a name
+	middlewareRegistered func(name string) bool
 }
 
 // GatewayConfig is the parent struct encapsulating all the
@@ -61,6 +127,14 @@ type GatewayConfig struct {
 	Description string `yaml:"description"`
 	Port        string `yaml:"port"`
 
+	// Time given to requests in flight to complete when the
+	// gateway shuts down, in the same format as rate limiter
+	// windows. Ex: 30S
+	ShutdownTimeoutString string `yaml:"shutdown_timeout"`
+
+	// ShutdownTimeout string converted into time.Duration
+	ShutdownTimeoutDuration time.Duration
+
 	// TLS configuration
 	EnableTLS       bool   `yaml:"enable_TLS"`
 	TLSCertFilePath string `yaml:"TLS_cert"`
@@ -71,15 +145,135 @@ type GatewayConfig struct {
 	LogOutput              string `yaml:"log_output"`
 	LogCredentialsFilePath string `yaml:"log_credentials"`
 
+	// Log file and its rotation for the FILE log output
+	LogFile LogFileConfig `yaml:"log_file"`
+
+	// Contents of the log credentials file
+	LogCredentials LogCredentials `yaml:"-"`
+
+	// Per request access log
+	AccessLog AccessLogConfig `yaml:"access_log"`
+
+	// Header request ids are received in and passed on in
+	RequestIDHeader string `yaml:"request_id_header"`
+
+	// Admin server, serving the health checks and the
+	// metrics of the gateway on a port of its own
+	AdminPort     string `yaml:"admin_port"`
+	EnableMetrics bool   `yaml:"enable_metrics"`
+
+	// Distributed tracing of the requests
+	Tracing TracingConfig `yaml:"tracing"`
+
 	// Gateway wide rate limiting
-	RateLimit RateLimiterConfig `yaml:"rate_limit"`
+	RateLimit  RateLimiterConfig   `yaml:"rate_limit"`
+	RateLimits []RateLimiterConfig `yaml:"rate_limits"`
+
+	// Proxies whose X-Forwarded-For header is trusted to
+	// carry the client's IP address. IPs or CIDR ranges.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// TrustedProxies parsed into IP networks
+	TrustedProxyNets []*net.IPNet
 
 	// GatewayWide CORS
 	CORS CORSConfig `yaml:"cors"`
 
+	// Middleware every request passes through before
+	// the middleware of the endpoint it is made to
+	Middleware []MiddlewareConfig `yaml:"middleware"`
+
+	// Largest request body the gateway reads into memory, to
+	// replay it to HTTP middleware. Ex: 10MB
+	MaxBodySizeString string `yaml:"max_body_size"`
+
+	// MaxBodySize string converted into bytes
+	MaxBodySizeBytes int64
+
+	// Gateway wide authentication and the file
+	// listing the consumers allowed to authenticate
+	Auth              AuthConfig `yaml:"auth"`
+	ConsumersFilePath string     `yaml:"consumers_file"`
+
+	// Glob patterns of the files, relative to the gateway file, whose
+	// endpoints are added to those of the gateway. Ex: teams/*.yml
+	Include []string `yaml:"include"`
+
 	Endpoints []EndpointConfig `yaml:"endpoints"`
 }
 
+// LogFileConfig encapsulates the configuration for writing logs to a file.
+// The file is rotated once it grows beyond MaxSize and, if Daily is set,
+// when the first line of a new day is logged. Rotated files are compressed
+// and only the latest MaxArchives of them are kept, 7 unless provided (0
+// counts as not provided). MaxSize is a string of format <size><unit> where
+// the unit is one of KB, MB or GB. Ex: 100MB.
+type LogFileConfig struct {
+	Path          string `yaml:"path"`
+	MaxSizeString string `yaml:"max_size"`
+	Daily         bool   `yaml:"daily"`
+	MaxArchives   int    `yaml:"max_archives"`
+
+	// MaxSize string converted into bytes
+	MaxSizeBytes int64
+}
+
+// AccessLogConfig encapsulates the configuration for logging a line per
+// request once it has been responded to. The format is one of JSON, the
+// Apache combined log format or a Go text/template executed with a
+// logging.AccessEntry. Access log lines are written with the application
+// logs unless Output is set to STDIO or FILE. File is the log file to use
+// with the FILE output.
+type AccessLogConfig struct {
+	Enabled  bool          `yaml:"enable"`
+	Format   string        `yaml:"format"`
+	Template string        `yaml:"template"`
+	Output   string        `yaml:"output"`
+	File     LogFileConfig `yaml:"file"`
+}
+
+// TracingConfig encapsulates the configuration for tracing requests with
+// OpenTelemetry. Spans are exported in batches to the OTLP/HTTP traces
+// endpoint of a collector, Ex: http://otel-collector:4318/v1/traces, along
+// with the Headers the collector may require. Spans are buffered in memory,
+// up to BufferSize of them, and dropped if the collector cannot keep up.
+// SampleRatio is the share of the requests starting a new trace that are
+// recorded, between 0 and 1. Requests that are part of a trace started
+// upstream are recorded if and only if the upstream service recorded them.
+type TracingConfig struct {
+	Enabled             bool              `yaml:"enable"`
+	Endpoint            string            `yaml:"endpoint"`
+	ServiceName         string            `yaml:"service_name"`
+	Headers             map[string]string `yaml:"headers"`
+	SampleRatio         *float64          `yaml:"sample_ratio"`
+	BufferSize          int               `yaml:"buffer_size"`
+	BatchSize           int               `yaml:"batch_size"`
+	FlushIntervalString string            `yaml:"flush_interval"`
+	TimeoutString       string            `yaml:"timeout"`
+
+	// FlushInterval and Timeout strings converted into time.Duration
+	FlushIntervalDuration time.Duration
+	TimeoutDuration       time.Duration
+}
+
+// EndpointTracingConfig encapsulates the tracing configuration an endpoint
+// can override. Tracing itself can only be configured on the gateway level.
+type EndpointTracingConfig struct {
+	SampleRatio *float64 `yaml:"sample_ratio"`
+}
+
+// SampleRatioFor returns the sample ratio applicable to an endpoint. The
+// endpoint level ratio takes precedence over the gateway wide one.
+func (gc *GatewayConfig) SampleRatioFor(e *EndpointConfig) float64 {
+	if e.Tracing.SampleRatio != nil {
+		return *e.Tracing.SampleRatio
+	}
+	if gc.Tracing.SampleRatio != nil {
+		return *gc.Tracing.SampleRatio
+	}
+	return 1
+}
+
 // RateLimiterConfig encapsulates the configuration for rate limiting. It
 // represents the number of requests allowed within a window of time and the
 // penalty to be levied if a user exceeds the specified rate limit.
@@ -91,26 +285,169 @@ type GatewayConfig struct {
 // When the config is loaded into memory, these duration strings are immediately
 // parsed and broken down into their individual components of length and unit
 // and then converted to a unified duration format to allow faster operations.
+// KeyBy decides which requests are counted together. By default all requests
+// share a single counter, but they can also be counted per client IP, per
+// authenticated consumer, per value of a header ('header:<name>') or per value
+// of a path parameter ('param:<name>'). Requests missing the key are counted
+// together, except for 'consumer', which falls back to the client IP.
 type RateLimiterConfig struct {
 	Enabled       bool   `yaml:"enable"`
 	Requests      uint   `yaml:"requests"`
 	WindowString  string `yaml:"window"`
 	PenaltyString string `yaml:"penalty"`
+	KeyBy         string `yaml:"key_by"`
+
+	// KeyBy string broken down into the type of key and,
+	// for headers and path parameters, the name of the key
+	KeyType string
+	KeyName string
 
 	// Window and Penalty strings converted into time.Duration
 	WindowDuration  time.Duration
 	PenaltyDuration time.Duration
 	PenaltyEnabled  bool
+
+	// Store holding the rate limiter counters. It can only be configured
+	// on the gateway level and is shared by all rate limiters.
+	Store RateLimitStoreConfig `yaml:"store"`
+}
+
+// RateLimitStoreConfig encapsulates the configuration of the store in which
+// rate limiters keep their counters. The MEMORY store keeps them within the
+// gateway process, which means that every gateway instance enforces the
+// limits independently. The REDIS store keeps them on a redis server shared
+// by all instances so that limits are enforced across the whole deployment.
+type RateLimitStoreConfig struct {
+	Type          string `yaml:"type"`
+	Address       string `yaml:"address"`
+	Password      string `yaml:"password"`
+	Database      uint   `yaml:"database"`
+	KeyPrefix     string `yaml:"key_prefix"`
+	PoolSize      uint   `yaml:"pool_size"`
+	TimeoutString string `yaml:"timeout"`
+
+	// Timeout string converted into time.Duration
+	TimeoutDuration time.Duration
 }
 
 // CORSConfig struct encapsulates the config for enabling CORS
-// on an API Wide level as well as per endpoint level
+// on an API Wide level as well as per endpoint level.
+// Allowed domains are matched against the host (and port) of the request's
+// origin. They can also include the scheme to only allow origins using it,
+// start with '*.' to allow all subdomains of a domain or be '*' to allow
+// all origins. MaxAge is a duration in the same format as rate limiter
+// windows and tells browsers how long they may cache a preflight response.
 type CORSConfig struct {
-	Enabled        bool     `yaml:"enable"`
-	AllowedMethods []string `yaml:"allowed_methods"`
-	AllowedDomains []string `yaml:"allowed_domains"`
-	AllowedHeaders []string `yaml:"allowed_headers"`
-	ExposedHeaders []string `yaml:"exposed_headers"`
+	Enabled          bool     `yaml:"enable"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedDomains   []string `yaml:"allowed_domains"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAgeString     string   `yaml:"max_age"`
+
+	// MaxAge string converted into time.Duration
+	MaxAgeDuration time.Duration
+}
+
+// CORSFor returns the CORS config applicable to an endpoint. The endpoint
+// level config takes precedence over the gateway wide one. Returns nil if
+// CORS is enabled on neither.
+func (gc *GatewayConfig) CORSFor(e *EndpointConfig) *CORSConfig {
+	if e.CORS.Enabled {
+		return &e.CORS
+	}
+	if gc.CORS.Enabled {
+		return &gc.CORS
+	}
+	return nil
+}
+
+// AuthConfig encapsulates the configuration for authenticating requests on
+// the gateway level as well as per endpoint level. Type selects the mechanism
+// used to authenticate the consumer sending the request. Endpoints can opt out
+// of gateway wide authentication with the NONE type. The name of the consumer
+// a request has been authenticated as is passed on to the backend in the
+// ConsumerHeader.
+type AuthConfig struct {
+	Enabled        bool             `yaml:"enable"`
+	Type           string           `yaml:"type"`
+	ConsumerHeader string           `yaml:"consumer_header"`
+	APIKey         APIKeyAuthConfig `yaml:"api_key"`
+	JWT            JWTAuthConfig    `yaml:"jwt"`
+	HMAC           HMACAuthConfig   `yaml:"hmac"`
+}
+
+// APIKeyAuthConfig encapsulates the configuration for authenticating requests
+// by a static API key. The key is read from the header, or if absent from the
+// query parameter, and looked up among the hashed keys of the consumers.
+type APIKeyAuthConfig struct {
+	Header     string `yaml:"header"`
+	QueryParam string `yaml:"query_param"`
+}
+
+// JWTAuthConfig encapsulates the configuration for authenticating requests by
+// a JWT sent as a bearer token in the Authorization header. Tokens are verified
+// against a shared Secret (HS256), a PEM encoded public key (RS256, ES256) or
+// the keys of a local JWKS file. Key files are reloaded when they change.
+// The exp and nbf claims are checked with Leeway to account for clock skew,
+// iss and aud are checked if Issuer and Audience are provided.
+// RequiredClaims maps claims to a value they must equal, or contain if the
+// claim is an array or a space delimited string such as 'scope'.
+// ForwardClaims maps claims to the headers they are passed on to the backend
+// in. The consumer the request has been authenticated as is the value of the
+// ConsumerClaim, 'sub' by default.
+type JWTAuthConfig struct {
+	Algorithms        []string          `yaml:"algorithms"`
+	Secret            string            `yaml:"secret"`
+	PublicKeyFilePath string            `yaml:"public_key"`
+	JWKSFilePath      string            `yaml:"jwks_file"`
+	Issuer            string            `yaml:"issuer"`
+	Audience          string            `yaml:"audience"`
+	LeewayString      string            `yaml:"leeway"`
+	ConsumerClaim     string            `yaml:"consumer_claim"`
+	RequiredClaims    map[string]string `yaml:"required_claims"`
+	ForwardClaims     map[string]string `yaml:"forward_claims"`
+
+	// Leeway string converted into time.Duration
+	LeewayDuration time.Duration
+}
+
+// HMACAuthConfig encapsulates the configuration for authenticating requests
+// signed with a token and secret pair issued to a consumer. The client sends
+// the token, the current unix time and the signature in the configured headers.
+// The signature is the hex encoded HMAC-SHA256, keyed with the secret, of
+//
+//	<METHOD>\n<request URI>\n<timestamp>\n<hex encoded SHA-256 of the body>
+//
+// Requests whose timestamp is further than ClockSkew from the gateway's clock
+// are rejected, so that captured requests cannot be replayed later on.
+type HMACAuthConfig struct {
+	TokenHeader     string `yaml:"token_header"`
+	TimestampHeader string `yaml:"timestamp_header"`
+	SignatureHeader string `yaml:"signature_header"`
+	ClockSkewString string `yaml:"clock_skew"`
+
+	// ClockSkew string converted into time.Duration
+	ClockSkewDuration time.Duration
+
+	// Largest body digested, the gateway's max body size
+	MaxBodySizeBytes int64
+}
+
+// AuthFor returns the auth config applicable to an endpoint. The endpoint
+// level config takes precedence over the gateway wide one. Returns nil if
+// authentication is enabled on neither, or the endpoint opts out of it.
+func (gc *GatewayConfig) AuthFor(e *EndpointConfig) *AuthConfig {
+	auth := &gc.Auth
+	if e.Auth.Enabled {
+		auth = &e.Auth
+	}
+
+	if !auth.Enabled || auth.Type == AuthTypeNone {
+		return nil
+	}
+	return auth
 }
 
 // EndpointConfig struct encapsulates the configuration required
@@ -118,66 +455,267 @@ type CORSConfig struct {
 // endpoints to override the default gateway wide configuration
 // for rate limiting and CORS
 type EndpointConfig struct {
-	ID          uint              `yaml:"id"`
-	Name        string            `yaml:"name"`
-	Description string            `yaml:"description"`
-	Method      string            `yaml:"method"`
-	Path        string            `yaml:"path"`
-	RateLimit   RateLimiterConfig `yaml:"rate_limit"`
-	CORS        CORSConfig        `yaml:"cors"`
-	Middleware  []string          `yaml:"middleware"`
-	Backend     string            `yaml:"backend"`
-}
-
-// scan method accepts the absolute path to the configuration file
-// and reads the content of the file into a byte array and returns it
-func (conf *Config) scan(configFilePath string) []byte {
-	filecontent, err := ioutil.ReadFile(configFilePath)
-	if err != nil {
-		log.Fatalf("Error while trying to read the config file %#v", err)
+	ID          uint                  `yaml:"id"`
+	Name        string                `yaml:"name"`
+	Description string                `yaml:"description"`
+	Method      string                `yaml:"method"`
+	Path        string                `yaml:"path"`
+	RateLimit   RateLimiterConfig     `yaml:"rate_limit"`
+	RateLimits  []RateLimiterConfig   `yaml:"rate_limits"`
+	CORS        CORSConfig            `yaml:"cors"`
+	Auth        AuthConfig            `yaml:"auth"`
+	Middleware  []MiddlewareConfig    `yaml:"middleware"`
+	Tracing     EndpointTracingConfig `yaml:"tracing"`
+	Backend     string                `yaml:"backend"`
+}
+
+// MiddlewareConfig encapsulates the configuration of a single middleware the
+// request passes through before it is proxied to the backend. Middleware are
+// either native Go middleware registered with the gateway package, referenced
+// by Name, or HTTP middleware called at URL. An HTTP middleware can be provided
+// either as a plain URL or as a map with the URL and its options.
+// Timeout is a duration in the same format as rate limiter windows. OnFailure
+// decides what happens if the middleware cannot be reached or times out:
+// FAIL_CLOSED (default) rejects the request, FAIL_OPEN skips the middleware.
+// A middleware can modify the request passed on to the rest of the chain and
+// the backend through its response headers. With a HeaderPrefix of 'X-Hodor-',
+// 'X-Hodor-Set-<name>' sets a request header, 'X-Hodor-Add-<name>' adds a value
+// to it and 'X-Hodor-Remove' lists request headers to strip. Only the headers
+// listed in MutableHeaders can be set or added to. ForwardHeaders lists
+// response headers copied onto the request as they are. Both the mutable and
+// the forwarded headers are removed from the client's request, so that only
+// the middleware can set them.
+type MiddlewareConfig struct {
+	Name           string   `yaml:"name"`
+	URL            string   `yaml:"url"`
+	TimeoutString  string   `yaml:"timeout"`
+	OnFailure      string   `yaml:"on_failure"`
+	HeaderPrefix   string   `yaml:"header_prefix"`
+	MutableHeaders []string `yaml:"mutable_headers"`
+	ForwardHeaders []string `yaml:"forward_headers"`
+
+	// Timeout string converted into time.Duration
+	TimeoutDuration time.Duration
+}
+
+// UnmarshalYAML allows a middleware to be provided as a plain URL string
+func (mw *MiddlewareConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var url string
+	if err := unmarshal(&url); err == nil {
+		mw.URL = url
+		return nil
 	}
-	return filecontent
+
+	type plain MiddlewareConfig
+	return unmarshal((*plain)(mw))
+}
+
+// EnabledRateLimits returns all rate limits enabled on the gateway level.
+// They apply to every endpoint that does not define rate limits of its own.
+func (gc *GatewayConfig) EnabledRateLimits() []*RateLimiterConfig {
+	return enabledRateLimits(&gc.RateLimit, gc.RateLimits)
+}
+
+// EnabledRateLimits returns all rate limits enabled on the endpoint. If
+// there are any, they take precedence over the gateway level rate limits.
+func (e *EndpointConfig) EnabledRateLimits() []*RateLimiterConfig {
+	return enabledRateLimits(&e.RateLimit, e.RateLimits)
+}
+
+func enabledRateLimits(rl *RateLimiterConfig, stacked []RateLimiterConfig) []*RateLimiterConfig {
+	var enabled []*RateLimiterConfig
+	if rl.Enabled {
+		enabled = append(enabled, rl)
+	}
+	for i := range stacked {
+		if stacked[i].Enabled {
+			enabled = append(enabled, &stacked[i])
+		}
+	}
+	return enabled
 }
 
-func (conf *Config) parse(filecontent []byte) {
-	err := yaml.Unmarshal(filecontent, &conf)
+// Load reads the config file at the path, relative to the working
+// directory or absolute, and loads the configuration from its content
+// with Parse. The path may also be that of a directory containing the
+// gateway file, config.yml, along with the files it includes.
+func Load(path string, options ...Option) (*Config, error) {
+	path = helpers.FilePathHelper.GetFullPath(path)
+	file, err := gatewayFilePath(path)
 	if err != nil {
-		log.Fatalf("Error while parsing the config file content into config struct, %#v", err)
+		return nil, fmt.Errorf("error while trying to read the config file :: %s", err)
 	}
-	conf.Initialised = true
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error while trying to read the config file :: %s", err)
+	}
+
+	conf, err := parse(content, path, file, options)
+	if errs, ok := err.(ValidationErrors); ok {
+		for _, e := range errs {
+			if e.File == "" {
+				e.File = file
+			}
+		}
+		return nil, errs
+	}
+	return conf, err
+}
+
+// Parse loads the configuration from the content of a config file. It
+// performs 4 crucial steps:
+// 1. Replace the references to environment variables and files with
+// their values. Ex: ${ENV_VAR}, ${ENV_VAR:-default}, ${file:/path}
+// 2. Parse the content into an instance of type Config, adding the
+// endpoints of the files it includes, relative to the working directory
+// 3. Validate the values loaded into the instance
+// 4. Optimise the instance
+// If a reference cannot be resolved, the content is not valid YAML or the
+// config fails validation, the error returned is of type ValidationErrors
+// and lists every rule broken.
+func Parse(content []byte, options ...Option) (*Config, error) {
+	return parse(content, "", "", options)
 }
 
-func (conf *Config) validate() {
+// parse loads the configuration from the content of the gateway file,
+// read from file for the config at path.
+func parse(content []byte, path string, file string, options []Option) (*Config, error) {
+	conf := &Config{ConfigFilePath: path, gatewayFile: file}
+	for _, option := range options {
+		option(conf)
+	}
+	resolved, errs := resolveTypedReferences(content, reflect.TypeOf(conf), "")
+	if err := yaml.Unmarshal(resolved, conf); err != nil {
+		return nil, yamlErrors(err)
+	}
+	conf.Errors = append(errs, interpolate(conf, "")...)
+	conf.Initialised = true
+	conf.addSource("", "", content)
+	conf.include()
+
 	conf.Gateway.validate(conf)
-	if conf.ValidationFailed {
-		log.Print("\n\n")
-		log.Fatalln("InvalidConfigError :: One or more validation rules failed. Please fix the above errors in the configuration file before continuing.")
+	if len(conf.Errors) > 0 {
+		conf.locate()
+		return nil, conf.Errors
+	}
+	conf.optimise()
+	return conf, nil
+}
+
+// yamlErrors converts the error met while unmarshalling a config into
+// validation errors.
+func yamlErrors(err error) ValidationErrors {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+
+	errs := make(ValidationErrors, len(messages))
+	for i, message := range messages {
+		message = strings.TrimPrefix(message, "yaml: ")
+		errs[i] = &ValidationError{
+			Code:    "InvalidYAML",
+			Message: message,
+			Line:    yamlErrorLine(message),
+		}
 	}
+	return errs
 }
 
 func (gc *GatewayConfig) validate(c *Config) {
 	gc.validateName(c)
 	gc.validatePort(c)
+	gc.validateShutdownTimeout(c)
+	gc.validateAdmin(c)
+	gc.Tracing.validate(c)
 	gc.validateTLS(c)
-	gc.RateLimit.validate(CFLevelGateway, c, nil)
+	gc.validateLogging(c)
+	gc.AccessLog.validate(c)
+	gc.validateRequestIDHeader(c)
+	gc.validateTrustedProxies(c)
+	gc.validateMaxBodySize(c)
+	gc.RateLimit.Store.validate(c)
+	gc.RateLimit.validate("gateway.rate_limit", CFLevelGateway, c, nil)
+	for i := range gc.RateLimits {
+		gc.RateLimits[i].validate(fmt.Sprintf("gateway.rate_limits[%d]", i), CFLevelGateway, c, nil)
+	}
+	gc.CORS.validate("gateway.cors", CFLevelGateway, c, nil)
+	for i := range gc.Middleware {
+		gc.Middleware[i].validate(fmt.Sprintf("gateway.middleware[%d]", i), CFLevelGateway, c, nil)
+	}
+	gc.validateConsumersFile(c)
+	gc.Auth.validate("gateway.auth", CFLevelGateway, c, nil)
 
-	for _, endpoint := range gc.Endpoints {
-		endpoint.validate(c)
+	for i := range gc.Endpoints {
+		gc.Endpoints[i].validate(fmt.Sprintf("gateway.endpoints[%d]", i), c)
 	}
+	gc.validateEndpointIDs(c)
+	gc.validateRoutes(c)
 }
 
 // @param c takes in the configuration
 func (gc *GatewayConfig) validateName(c *Config) {
 	if gc.Name == "" {
-		log.Printf("\t - Error.InvalidGatewayName :: Invalid value '%s' provided. The name field will be used to uniquely identify the gateway in logs and monitoring systems. Please provide a valid name\n", gc.Name)
-		c.ValidationFailed = true
+		c.fail("InvalidGatewayName", "gateway.name", gc.Name, "Invalid value '%s' provided. The name field will be used to uniquely identify the gateway in logs and monitoring systems. Please provide a valid name", gc.Name)
 	}
 }
 
 func (gc *GatewayConfig) validatePort(c *Config) {
 	if !portRegex.MatchString(gc.Port) {
-		log.Printf("\t - Error.InvalidPort :: Invalid value '%s' provided. Please provide a valid port number in the format ':<portnumber>' to run the gateway\n", gc.Port)
-		c.ValidationFailed = true
+		c.fail("InvalidPort", "gateway.port", gc.Port, "Invalid value '%s' provided. Please provide a valid port number in the format ':<portnumber>' to run the gateway", gc.Port)
+	}
+}
+
+func (gc *GatewayConfig) validateShutdownTimeout(c *Config) {
+	if gc.ShutdownTimeoutString != "" && (!durationRegex.MatchString(gc.ShutdownTimeoutString) || stringToDuration(gc.ShutdownTimeoutString) <= 0) {
+		c.fail("InvalidShutdownTimeout", "gateway.shutdown_timeout", gc.ShutdownTimeoutString, "Invalid value '%s' provided for shutdown timeout. Please provide a valid string of format <length><time_unit> with a length of at least 1. Ex: 30S or 1M.", gc.ShutdownTimeoutString)
+	}
+}
+
+func (gc *GatewayConfig) validateAdmin(c *Config) {
+	if gc.AdminPort == "" {
+		if gc.EnableMetrics {
+			c.fail("MissingAdminPort", "gateway.admin_port", gc.AdminPort, "Metrics are enabled but no admin port has been provided. Please provide the port to serve metrics on in admin_port in the format ':<portnumber>'")
+		}
+		return
+	}
+
+	if !portRegex.MatchString(gc.AdminPort) {
+		c.fail("InvalidAdminPort", "gateway.admin_port", gc.AdminPort, "Invalid value '%s' provided. Please provide a valid port number in the format ':<portnumber>' to run the admin server", gc.AdminPort)
+	} else if gc.AdminPort == gc.Port {
+		c.fail("InvalidAdminPort", "gateway.admin_port", gc.AdminPort, "The admin port '%s' is the same as the port of the gateway. Please provide a port of its own to run the admin server", gc.AdminPort)
+	}
+}
+
+func (tc *TracingConfig) validate(c *Config) {
+	if !tc.Enabled {
+		return
+	}
+
+	if parsed, err := url.Parse(tc.Endpoint); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.fail("InvalidTracingEndpoint", "gateway.tracing.endpoint", tc.Endpoint, "Invalid value '%s' provided for tracing endpoint. Please provide the http or https URL of the OTLP traces endpoint of the collector. Ex: http://otel-collector:4318/v1/traces", tc.Endpoint)
+	}
+
+	if tc.SampleRatio != nil {
+		validateSampleRatio("gateway.tracing.sample_ratio", *tc.SampleRatio, c)
+	}
+
+	for _, size := range []keyedInt{{"buffer_size", tc.BufferSize}, {"batch_size", tc.BatchSize}} {
+		if size.value < 0 {
+			c.fail("InvalidTracingBufferSize", "gateway.tracing."+size.key, size.value, "Invalid value '%d' provided for tracing %s. Please provide a positive number.", size.value, size.key)
+		}
+	}
+
+	for _, duration := range []keyedString{{"flush_interval", tc.FlushIntervalString}, {"timeout", tc.TimeoutString}} {
+		if duration.value != "" && (!durationRegex.MatchString(duration.value) || stringToDuration(duration.value) <= 0) {
+			c.fail("InvalidTracingDuration", "gateway.tracing."+duration.key, duration.value, "Invalid value '%s' provided for tracing %s. Please provide a valid string of format <length><time_unit> with a length of at least 1. Ex: 5S or 10S.", duration.value, duration.key)
+		}
+	}
+}
+
+func validateSampleRatio(field string, ratio float64, c *Config) {
+	if ratio < 0 || ratio > 1 {
+		c.fail("InvalidSampleRatio", field, ratio, "Invalid value '%g' provided for tracing sample ratio. Please provide a number between 0 and 1.", ratio)
 	}
 }
 
@@ -190,21 +728,225 @@ func (gc *GatewayConfig) validateTLS(c *Config) {
 	gc.TLSKeyFilePath = helpers.FilePathHelper.GetFullPath(gc.TLSKeyFilePath)
 
 	if !helpers.FilePathHelper.IsValidPath(gc.TLSCertFilePath) {
-		log.Printf("\t - Error.InvalidCertPath :: Invalid filepath '%s' provided for TLS certificate file\n", gc.TLSCertFilePath)
-		c.ValidationFailed = true
+		c.fail("InvalidCertPath", "gateway.TLS_cert", gc.TLSCertFilePath, "Invalid filepath '%s' provided for TLS certificate file", gc.TLSCertFilePath)
 	}
 
 	if !helpers.FilePathHelper.IsValidPath(gc.TLSKeyFilePath) {
-		log.Printf("\t - Error.InvalidKeyPath :: Invalid filepath '%s' provided for TLS key file\n", gc.TLSKeyFilePath)
-		c.ValidationFailed = true
+		c.fail("InvalidKeyPath", "gateway.TLS_key", gc.TLSKeyFilePath, "Invalid filepath '%s' provided for TLS key file", gc.TLSKeyFilePath)
 	}
 }
 
-func (rl *RateLimiterConfig) validate(rlType string, c *Config, e *EndpointConfig) {
-	if !rl.Enabled {
+func (gc *GatewayConfig) validateLogging(c *Config) {
+	switch strings.ToUpper(gc.LogLevel) {
+	case "", LogLevelDebug, LogLevelInfo, LogLevelWarning, LogLevelError:
+	default:
+		c.fail("InvalidLogLevel", "gateway.log_level", gc.LogLevel, "Invalid value '%s' provided for log level. Allowed values are DEBUG, INFO, WARNING and ERROR (case insensitive).", gc.LogLevel)
+	}
+
+	switch output := strings.ToUpper(gc.LogOutput); output {
+	case "", LogOutputStdio:
+	case LogOutputFile:
+		gc.LogFile.validate("gateway.log_file", c)
+	case LogOutputKafka, LogOutputELK:
+		if gc.LogCredentialsFilePath == "" {
+			c.fail("MissingLogCredentials", "gateway.log_credentials", gc.LogCredentialsFilePath, "Log output %s requires a log credentials file. Please provide its path in log_credentials.", output)
+		}
+	default:
+		c.fail("InvalidLogOutput", "gateway.log_output", gc.LogOutput, "Invalid value '%s' provided for log output. Allowed values are STDIO, FILE, KAFKA and ELK (case insensitive).", gc.LogOutput)
+	}
+
+	if gc.LogCredentialsFilePath == "" {
+		return
+	}
+
+	gc.LogCredentialsFilePath = helpers.FilePathHelper.GetFullPath(gc.LogCredentialsFilePath)
+	if !helpers.FilePathHelper.IsValidPath(gc.LogCredentialsFilePath) {
+		c.fail("InvalidLogCredentialsPath", "gateway.log_credentials", gc.LogCredentialsFilePath, "Invalid filepath '%s' provided for log credentials file", gc.LogCredentialsFilePath)
 		return
 	}
 
+	gc.LogCredentials.load(gc.LogCredentialsFilePath, c)
+	switch strings.ToUpper(gc.LogOutput) {
+	case LogOutputKafka:
+		gc.LogCredentials.Kafka.validate(c)
+	case LogOutputELK:
+		gc.LogCredentials.ELK.validate(c)
+	}
+}
+
+func (lf *LogFileConfig) validate(field string, c *Config) {
+	if lf.Path == "" {
+		c.fail("MissingLogFilePath", field+".path", lf.Path, "Log output FILE requires the path of the file to write logs to. Please provide it in %s.path.", field)
+	} else {
+		lf.Path = helpers.FilePathHelper.GetFullPath(lf.Path)
+		if !helpers.FilePathHelper.IsValidPath(filepath.Dir(lf.Path)) {
+			c.fail("InvalidLogFilePath", field+".path", lf.Path, "Invalid filepath '%s' provided for log file. The directory it is in must exist.", lf.Path)
+		}
+	}
+
+	if lf.MaxSizeString != "" && (!sizeRegex.MatchString(lf.MaxSizeString) || stringToSize(lf.MaxSizeString) <= 0) {
+		c.fail("InvalidLogFileMaxSize", field+".max_size", lf.MaxSizeString, "Invalid value '%s' provided for log file max size. Please provide a valid string of format <size><unit> where unit is one of KB, MB or GB, with a size of at least 1. Ex: 100MB.", lf.MaxSizeString)
+	}
+
+	if lf.MaxArchives < 0 {
+		c.fail("InvalidLogFileMaxArchives", field+".max_archives", lf.MaxArchives, "Invalid value '%d' provided for log file max archives. Please provide a positive number.", lf.MaxArchives)
+	}
+}
+
+func (al *AccessLogConfig) validate(c *Config) {
+	if !al.Enabled {
+		return
+	}
+
+	switch strings.ToUpper(al.Format) {
+	case "", AccessLogJSON, AccessLogCombined:
+	case AccessLogTemplate:
+		if al.Template == "" {
+			c.fail("MissingAccessLogTemplate", "gateway.access_log.template", al.Template, "Access log format TEMPLATE requires a template. Please provide it in access_log.template. Ex: {{.Method}} {{.URI}} {{.Status}} {{.Latency}}")
+		} else if _, err := template.New("access_log").Parse(al.Template); err != nil {
+			c.fail("InvalidAccessLogTemplate", "gateway.access_log.template", al.Template, "Invalid value '%s' provided for access log template :: %s", al.Template, err)
+		}
+	default:
+		c.fail("InvalidAccessLogFormat", "gateway.access_log.format", al.Format, "Invalid value '%s' provided for access log format. Allowed values are JSON, COMBINED and TEMPLATE (case insensitive).", al.Format)
+	}
+
+	switch strings.ToUpper(al.Output) {
+	case "", LogOutputStdio:
+	case LogOutputFile:
+		al.File.validate("gateway.access_log.file", c)
+	default:
+		c.fail("InvalidAccessLogOutput", "gateway.access_log.output", al.Output, "Invalid value '%s' provided for access log output. Allowed values are STDIO and FILE (case insensitive), or none to write access logs with the application logs.", al.Output)
+	}
+}
+
+func (gc *GatewayConfig) validateRequestIDHeader(c *Config) {
+	if gc.RequestIDHeader != "" && !headerNameRegex.MatchString(gc.RequestIDHeader) {
+		c.fail("InvalidRequestIDHeader", "gateway.request_id_header", gc.RequestIDHeader, "Invalid value '%s' provided for request id header. Please provide a valid header name. Ex: X-Request-ID", gc.RequestIDHeader)
+	}
+}
+
+func (gc *GatewayConfig) validateMaxBodySize(c *Config) {
+	if gc.MaxBodySizeString != "" && (!sizeRegex.MatchString(gc.MaxBodySizeString) || stringToSize(gc.MaxBodySizeString) <= 0) {
+		c.fail("InvalidMaxBodySize", "gateway.max_body_size", gc.MaxBodySizeString, "Invalid value '%s' provided for max body size. Please provide a valid string of format <size><unit> where unit is one of KB, MB or GB, with a size of at least 1. Ex: 10MB.", gc.MaxBodySizeString)
+	}
+}
+
+func (gc *GatewayConfig) validateTrustedProxies(c *Config) {
+	for i, proxy := range gc.TrustedProxies {
+		if _, err := parseIPNet(proxy); err != nil {
+			c.fail("InvalidTrustedProxy", fmt.Sprintf("gateway.trusted_proxies[%d]", i), proxy, "Invalid value '%s' provided for trusted proxies. Please provide a valid IP address or CIDR range. Ex: 10.0.0.1 or 10.0.0.0/8", proxy)
+		}
+	}
+}
+
+func (gc *GatewayConfig) validateConsumersFile(c *Config) {
+	if gc.ConsumersFilePath == "" {
+		return
+	}
+
+	gc.ConsumersFilePath = helpers.FilePathHelper.GetFullPath(gc.ConsumersFilePath)
+	if !helpers.FilePathHelper.IsValidPath(gc.ConsumersFilePath) {
+		c.fail("InvalidConsumersPath", "gateway.consumers_file", gc.ConsumersFilePath, "Invalid filepath '%s' provided for consumers file", gc.ConsumersFilePath)
+	}
+}
+
+func (auth *AuthConfig) validate(field string, authType string, c *Config, e *EndpointConfig) {
+	if !auth.Enabled {
+		return
+	}
+
+	var authTypeString string
+	if authType == CFLevelGateway {
+		authTypeString = "the gateway"
+	} else {
+		authTypeString = fmt.Sprintf("endpoint '%s'", e.Name)
+	}
+
+	if auth.ConsumerHeader != "" && !headerNameRegex.MatchString(auth.ConsumerHeader) {
+		c.fail("InvalidAuthConsumerHeader", field+".consumer_header", auth.ConsumerHeader, "Invalid value '%s' provided for auth consumer header for %s. Please provide a valid header name. Ex: X-Consumer", auth.ConsumerHeader, authTypeString)
+	}
+
+	switch strings.ToUpper(auth.Type) {
+	case AuthTypeNone:
+
+	case AuthTypeAPIKey:
+		if c.Gateway.ConsumersFilePath == "" {
+			c.fail("MissingConsumersFile", "gateway.consumers_file", c.Gateway.ConsumersFilePath, "API key authentication is enabled for %s but no consumers file has been provided. Please provide the path to the file listing the consumers and their hashed API keys in consumers_file.", authTypeString)
+		}
+		if auth.APIKey.Header != "" && !headerNameRegex.MatchString(auth.APIKey.Header) {
+			c.fail("InvalidAPIKeyHeader", field+".api_key.header", auth.APIKey.Header, "Invalid value '%s' provided for API key header for %s. Please provide a valid header name. Ex: X-Api-Key", auth.APIKey.Header, authTypeString)
+		}
+
+	case AuthTypeJWT:
+		auth.JWT.validate(field+".jwt", authTypeString, c)
+
+	case AuthTypeHMAC:
+		if c.Gateway.ConsumersFilePath == "" {
+			c.fail("MissingConsumersFile", "gateway.consumers_file", c.Gateway.ConsumersFilePath, "HMAC authentication is enabled for %s but no consumers file has been provided. Please provide the path to the file listing the consumers and their HMAC keys in consumers_file.", authTypeString)
+		}
+		auth.HMAC.validate(field+".hmac", authTypeString, c)
+
+	default:
+		c.fail("InvalidAuthType", field+".type", auth.Type, "Invalid value '%s' provided for auth type for %s. Allowed values are NONE, API_KEY, JWT and HMAC (case insensitive).", auth.Type, authTypeString)
+	}
+}
+
+func (hmac *HMACAuthConfig) validate(field string, authTypeString string, c *Config) {
+	headers := []keyedString{
+		{"token_header", hmac.TokenHeader},
+		{"timestamp_header", hmac.TimestampHeader},
+		{"signature_header", hmac.SignatureHeader},
+	}
+	for _, header := range headers {
+		if header.value != "" && !headerNameRegex.MatchString(header.value) {
+			c.fail("InvalidHMACHeader", field+"."+header.key, header.value, "Invalid value '%s' provided for HMAC header for %s. Please provide a valid header name. Ex: X-Signature", header.value, authTypeString)
+		}
+	}
+
+	if hmac.ClockSkewString != "" && (!durationRegex.MatchString(hmac.ClockSkewString) || stringToDuration(hmac.ClockSkewString) <= 0) {
+		c.fail("InvalidHMACClockSkew", field+".clock_skew", hmac.ClockSkewString, "Invalid value '%s' provided for HMAC clock skew for %s. Please provide a valid string of format <length><time_unit> with a length of at least 1. Ex: 30S or 5M.", hmac.ClockSkewString, authTypeString)
+	}
+}
+
+func (jwt *JWTAuthConfig) validate(field string, authTypeString string, c *Config) {
+	if jwt.Secret == "" && jwt.PublicKeyFilePath == "" && jwt.JWKSFilePath == "" {
+		c.fail("MissingJWTKey", field, "", "No key provided to verify JWTs for %s. Please provide a secret, a public key file or a JWKS file.", authTypeString)
+	}
+
+	for i, alg := range jwt.Algorithms {
+		switch strings.ToUpper(alg) {
+		case JWTAlgHS256, JWTAlgRS256, JWTAlgES256:
+		default:
+			c.fail("InvalidJWTAlgorithm", fmt.Sprintf("%s.algorithms[%d]", field, i), alg, "Invalid value '%s' provided for JWT algorithms for %s. Allowed values are HS256, RS256 and ES256 (case insensitive).", alg, authTypeString)
+		}
+	}
+
+	if jwt.PublicKeyFilePath != "" {
+		jwt.PublicKeyFilePath = helpers.FilePathHelper.GetFullPath(jwt.PublicKeyFilePath)
+		if !helpers.FilePathHelper.IsValidPath(jwt.PublicKeyFilePath) {
+			c.fail("InvalidJWTPublicKeyPath", field+".public_key", jwt.PublicKeyFilePath, "Invalid filepath '%s' provided for JWT public key file for %s", jwt.PublicKeyFilePath, authTypeString)
+		}
+	}
+
+	if jwt.JWKSFilePath != "" {
+		jwt.JWKSFilePath = helpers.FilePathHelper.GetFullPath(jwt.JWKSFilePath)
+		if !helpers.FilePathHelper.IsValidPath(jwt.JWKSFilePath) {
+			c.fail("InvalidJWKSPath", field+".jwks_file", jwt.JWKSFilePath, "Invalid filepath '%s' provided for JWKS file for %s", jwt.JWKSFilePath, authTypeString)
+		}
+	}
+
+	if jwt.LeewayString != "" && !durationRegex.MatchString(jwt.LeewayString) {
+		c.fail("InvalidJWTLeeway", field+".leeway", jwt.LeewayString, "Invalid value '%s' provided for JWT leeway for %s. Please provide a valid string of format <length><time_unit>. Ex: 30S or 1M.", jwt.LeewayString, authTypeString)
+	}
+
+	for claim, header := range jwt.ForwardClaims {
+		if !headerNameRegex.MatchString(header) {
+			c.fail("InvalidJWTForwardHeader", field+".forward_claims."+claim, header, "Invalid value '%s' provided as header for JWT claim '%s' for %s. Please provide a valid header name. Ex: X-User-Id", header, claim, authTypeString)
+		}
+	}
+}
+
+func (rl *RateLimiterConfig) validate(field string, rlType string, c *Config, e *EndpointConfig) {
 	var rlTypeString string
 	if rlType == CFLevelGateway {
 		rlTypeString = "the gateway"
@@ -212,58 +954,207 @@ func (rl *RateLimiterConfig) validate(rlType string, c *Config, e *EndpointConfi
 		rlTypeString = fmt.Sprintf("endpoint '%s'", e.Name)
 	}
 
+	// The store is shared by all rate limiters and can only be
+	// configured on the gateway's primary rate limit
+	if rl != &c.Gateway.RateLimit && rl.Store.Type != "" {
+		c.fail("InvalidRateLimitStore", field+".store.type", rl.Store.Type, "A rate limiter store has been provided for a rate limit of %s. The store can only be configured under the gateway's rate_limit and is shared by all rate limiters.", rlTypeString)
+	}
+
+	if !rl.Enabled {
+		return
+	}
+
 	if rl.Requests == 0 {
-		log.Printf("\t - Error.InvalidNumberOfRequests :: Invalid value '%d' provided for rate limiter's allowed number of requests for %s. Please provide a valid integer denoting the number of requests to allow in the specified time window or set the enable flaf to false.\n", rl.Requests, rlTypeString)
-		c.ValidationFailed = true
+		c.fail("InvalidNumberOfRequests", field+".requests", rl.Requests, "Invalid value '%d' provided for rate limiter's allowed number of requests for %s. Please provide a valid integer denoting the number of requests to allow in the specified time window or set the enable flaf to false.", rl.Requests, rlTypeString)
 		rl.Enabled = false
 	}
 
-	if !durationRegex.MatchString(rl.WindowString) {
-		log.Printf("\t - Error.InvalidRateLimitWindow :: Invalid value '%s' provided for rate limiter window for %s. Please provide a valid string of format <window_length><time_unit>. Ex: 10S or 2M or 1H or set the enable flag to false.\n", rl.WindowString, rlTypeString)
-		c.ValidationFailed = true
+	if !durationRegex.MatchString(rl.WindowString) || stringToDuration(rl.WindowString) <= 0 {
+		c.fail("InvalidRateLimitWindow", field+".window", rl.WindowString, "Invalid value '%s' provided for rate limiter window for %s. Please provide a valid string of format <window_length><time_unit> with a length of at least 1. Ex: 10S or 2M or 1H or set the enable flag to false.", rl.WindowString, rlTypeString)
 		rl.Enabled = false
 	}
 
 	if !durationRegex.MatchString(rl.PenaltyString) && rl.PenaltyString != "-" {
-		log.Printf("\t - Error.InvalidRateLimitPenalty :: Invalid value '%s' provided for rate limiter penalty for %s. Please provide a valid string of format <window_length><time_unit>. Ex: 10S or 2M or 1H OR provide a '-' to ignore penalty.\n", rl.PenaltyString, rlTypeString)
-		c.ValidationFailed = true
+		c.fail("InvalidRateLimitPenalty", field+".penalty", rl.PenaltyString, "Invalid value '%s' provided for rate limiter penalty for %s. Please provide a valid string of format <window_length><time_unit>. Ex: 10S or 2M or 1H OR provide a '-' to ignore penalty.", rl.PenaltyString, rlTypeString)
+		rl.Enabled = false
+	}
+
+	if rl.KeyBy != "" && !keyByRegex.MatchString(rl.KeyBy) {
+		c.fail("InvalidRateLimitKey", field+".key_by", rl.KeyBy, "Invalid value '%s' provided for rate limiter key for %s. Allowed values are all, ip, consumer, header:<header_name> and param:<path_parameter_name>.", rl.KeyBy, rlTypeString)
+		rl.Enabled = false
+		return
+	}
+
+	// An endpoint can only be rate limited by the path parameters it has
+	keyType, keyName := splitKeyBy(rl.KeyBy)
+	if rlType == CFLevelEndpoint && keyType == RLKeyParam && !hasPathParam(e.Path, keyName) {
+		c.fail("InvalidRateLimitKey", field+".key_by", rl.KeyBy, "Invalid value '%s' provided for rate limiter key for %s. The path '%s' has no parameter named '%s'.", rl.KeyBy, rlTypeString, e.Path, keyName)
 		rl.Enabled = false
 	}
 }
 
-func (e *EndpointConfig) validate(c *Config) {
-	e.validateName(c)
-	e.validateMethod(c)
-	e.validatePath(c)
-	e.validateBackend(c)
-	e.RateLimit.validate(CFLevelEndpoint, c, e)
+func (cors *CORSConfig) validate(field string, corsType string, c *Config, e *EndpointConfig) {
+	if !cors.Enabled {
+		return
+	}
+
+	var corsTypeString string
+	if corsType == CFLevelGateway {
+		corsTypeString = "the gateway"
+	} else {
+		corsTypeString = fmt.Sprintf("endpoint '%s'", e.Name)
+	}
+
+	if len(cors.AllowedDomains) == 0 {
+		c.fail("InvalidCORSDomains", field+".allowed_domains", "", "No allowed domains provided for CORS for %s. Please provide at least one domain or '*' to allow all origins.", corsTypeString)
+	}
+
+	for i, domain := range cors.AllowedDomains {
+		if domain == "" || (strings.Contains(domain, "*") && domain != "*" && !strings.HasPrefix(domain, "*.")) {
+			c.fail("InvalidCORSDomain", fmt.Sprintf("%s.allowed_domains[%d]", field, i), domain, "Invalid value '%s' provided for CORS allowed domains for %s. Please provide a domain (Ex: yourwebsite.com or localhost:1337), a wildcard subdomain (Ex: *.yourwebsite.com) or '*'.", domain, corsTypeString)
+		}
+	}
+
+	for i, method := range cors.AllowedMethods {
+		if !methodsRegex.MatchString(method) {
+			c.fail("InvalidCORSMethod", fmt.Sprintf("%s.allowed_methods[%d]", field, i), method, "Invalid value '%s' provided for CORS allowed methods for %s. Please provide valid methods. Ex. GET, PUT, POST, DELETE, PATCH, OPTIONS (case insensitive)", method, corsTypeString)
+		}
+	}
+
+	if cors.MaxAgeString != "" && !durationRegex.MatchString(cors.MaxAgeString) {
+		c.fail("InvalidCORSMaxAge", field+".max_age", cors.MaxAgeString, "Invalid value '%s' provided for CORS max age for %s. Please provide a valid string of format <length><time_unit>. Ex: 10S or 2M or 1H.", cors.MaxAgeString, corsTypeString)
+	}
+}
+
+func (st *RateLimitStoreConfig) validate(c *Config) {
+	switch strings.ToUpper(st.Type) {
+	case "", RLStoreMemory:
+
+	case RLStoreRedis:
+		if !portRegex.MatchString(st.Address) {
+			c.fail("InvalidRateLimitStoreAddress", "gateway.rate_limit.store.address", st.Address, "Invalid value '%s' provided for the redis rate limiter store address. Please provide a valid address in the format '<host>:<port>'.", st.Address)
+		}
+
+	default:
+		c.fail("InvalidRateLimitStore", "gateway.rate_limit.store.type", st.Type, "Invalid value '%s' provided for the rate limiter store type. Allowed values are MEMORY and REDIS (case insensitive).", st.Type)
+	}
+
+	if st.TimeoutString != "" && (!durationRegex.MatchString(st.TimeoutString) || stringToDuration(st.TimeoutString) <= 0) {
+		c.fail("InvalidRateLimitStoreTimeout", "gateway.rate_limit.store.timeout", st.TimeoutString, "Invalid value '%s' provided for the rate limiter store timeout. Please provide a valid string of format <length><time_unit> with a length of at least 1. Ex: 1S or 2M.", st.TimeoutString)
+	}
+}
+
+func (e *EndpointConfig) validate(field string, c *Config) {
+	e.validateName(field, c)
+	e.validateMethod(field, c)
+	e.validatePath(field, c)
+	e.validateBackend(field, c)
+	e.RateLimit.validate(field+".rate_limit", CFLevelEndpoint, c, e)
+	for i := range e.RateLimits {
+		e.RateLimits[i].validate(fmt.Sprintf("%s.rate_limits[%d]", field, i), CFLevelEndpoint, c, e)
+	}
+	e.CORS.validate(field+".cors", CFLevelEndpoint, c, e)
+	e.Auth.validate(field+".auth", CFLevelEndpoint, c, e)
+	for i := range e.Middleware {
+		e.Middleware[i].validate(fmt.Sprintf("%s.middleware[%d]", field, i), CFLevelEndpoint, c, e)
+	}
+	if e.Tracing.SampleRatio != nil {
+		validateSampleRatio(field+".tracing.sample_ratio", *e.Tracing.SampleRatio, c)
+	}
 }
 
-func (e *EndpointConfig) validateName(c *Config) {
+func (mw *MiddlewareConfig) validate(field string, mwType string, c *Config, e *EndpointConfig) {
+	var mwTypeString string
+	if mwType == CFLevelGateway {
+		mwTypeString = "the gateway"
+	} else {
+		mwTypeString = fmt.Sprintf("endpoint '%s'", e.Name)
+	}
+
+	// Native middleware are referenced by name and implemented
+	// in Go, none of the HTTP middleware options apply to them
+	if mw.Name != "" {
+		if mw.URL != "" {
+			c.fail("InvalidMiddleware", field+".url", mw.URL, "Both a name '%s' and a URL '%s' provided for middleware of %s. Please provide a name to use a registered middleware or a URL to call an HTTP middleware.", mw.Name, mw.URL, mwTypeString)
+		}
+		if c.middlewareRegistered != nil && !c.middlewareRegistered(mw.Name) {
+			c.fail("UnknownMiddleware", field+".name", mw.Name, "No middleware registered under the name '%s' for middleware of %s. Please register it with gateway.RegisterMiddleware before loading the config or provide the URL of an HTTP middleware.", mw.Name, mwTypeString)
+		}
+		return
+	}
+
+	u, err := url.Parse(mw.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.fail("InvalidMiddleware", field+".url", mw.URL, "Invalid value '%s' provided for middleware of %s. Please provide a valid http or https URL or the name of a registered middleware.", mw.URL, mwTypeString)
+	}
+
+	if mw.TimeoutString != "" && (!durationRegex.MatchString(mw.TimeoutString) || stringToDuration(mw.TimeoutString) <= 0) {
+		c.fail("InvalidMiddlewareTimeout", field+".timeout", mw.TimeoutString, "Invalid value '%s' provided for timeout of middleware '%s' of %s. Please provide a valid string of format <length><time_unit> with a length of at least 1. Ex: 1S or 2M.", mw.TimeoutString, mw.URL, mwTypeString)
+	}
+
+	switch strings.ToUpper(mw.OnFailure) {
+	case "", MWFailOpen, MWFailClosed:
+	default:
+		c.fail("InvalidMiddlewareFailurePolicy", field+".on_failure", mw.OnFailure, "Invalid value '%s' provided for failure policy of middleware '%s' of %s. Allowed values are FAIL_OPEN and FAIL_CLOSED (case insensitive).", mw.OnFailure, mw.URL, mwTypeString)
+	}
+
+	if mw.HeaderPrefix != "" && !headerNameRegex.MatchString(mw.HeaderPrefix) {
+		c.fail("InvalidMiddlewareHeaderPrefix", field+".header_prefix", mw.HeaderPrefix, "Invalid value '%s' provided for header prefix of middleware '%s' of %s. Please provide a valid header name prefix. Ex: X-Hodor-", mw.HeaderPrefix, mw.URL, mwTypeString)
+	}
+
+	if len(mw.MutableHeaders) > 0 && mw.HeaderPrefix == "" {
+		c.fail("InvalidMiddlewareMutableHeaders", field+".mutable_headers", strings.Join(mw.MutableHeaders, ", "), "Mutable headers provided for middleware '%s' of %s without a header prefix. Please provide the header_prefix the middleware sets them with. Ex: X-Hodor-", mw.URL, mwTypeString)
+	}
+	for i, header := range mw.MutableHeaders {
+		if !headerNameRegex.MatchString(header) {
+			c.fail("InvalidMiddlewareMutableHeader", fmt.Sprintf("%s.mutable_headers[%d]", field, i), header, "Invalid value '%s' provided for mutable headers of middleware '%s' of %s. Please provide a valid header name. Ex: X-User-Id", header, mw.URL, mwTypeString)
+		}
+	}
+
+	for i, header := range mw.ForwardHeaders {
+		if !headerNameRegex.MatchString(header) {
+			c.fail("InvalidMiddlewareForwardHeader", fmt.Sprintf("%s.forward_headers[%d]", field, i), header, "Invalid value '%s' provided for forwarded headers of middleware '%s' of %s. Please provide a valid header name. Ex: X-User-Id", header, mw.URL, mwTypeString)
+		}
+	}
+}
+
+// validateEndpointIDs makes sure that no two endpoints share an ID, since
+// endpoints are identified by their IDs in logs, metrics and reloads.
+func (gc *GatewayConfig) validateEndpointIDs(c *Config) {
+	seen := map[uint]int{}
+	for i, e := range gc.Endpoints {
+		if e.ID == 0 {
+			continue
+		}
+		if j, ok := seen[e.ID]; ok {
+			c.fail("DuplicateEndpointID", fmt.Sprintf("gateway.endpoints[%d].id", i), e.ID, "Endpoint ID %d of endpoint %s is already used by endpoint %s. Please provide a unique ID", e.ID, e.Name, c.describeEndpoint(j, i))
+			continue
+		}
+		seen[e.ID] = i
+	}
+}
+
+func (e *EndpointConfig) validateName(field string, c *Config) {
 	if e.Name == "" {
-		log.Printf("\t - Error.InvalidEndpointName :: Invalid value '%s' provided. The name field will be used to uniquely identify your endpoints in logs and monitoring systems. Please provide a valid name\n", e.Name)
-		c.ValidationFailed = true
+		c.fail("InvalidEndpointName", field+".name", e.Name, "Invalid value '%s' provided. The name field will be used to uniquely identify your endpoints in logs and monitoring systems. Please provide a valid name", e.Name)
 	}
 }
 
-func (e *EndpointConfig) validateMethod(c *Config) {
+func (e *EndpointConfig) validateMethod(field string, c *Config) {
 	if !methodsRegex.MatchString(e.Method) {
-		log.Printf("\t - Error.InvalidMethod :: Invalid value '%s' provided for endpoint %s. Please provide a valid method field. Ex. GET, PUT, POST, DELETE, PATCH, OPTIONS (case insensitive)\n", e.Method, e.Name)
-		c.ValidationFailed = true
+		c.fail("InvalidMethod", field+".method", e.Method, "Invalid value '%s' provided for endpoint %s. Please provide a valid method field. Ex. GET, PUT, POST, DELETE, PATCH, OPTIONS (case insensitive)", e.Method, e.Name)
 	}
 }
 
-func (e *EndpointConfig) validatePath(c *Config) {
+func (e *EndpointConfig) validatePath(field string, c *Config) {
 	if e.Path == "" {
-		log.Printf("\t - Error.InvalidPath :: Invalid value '%s' provided for endpoint %s. Please provide a valid path.\n", e.Path, e.Name)
-		c.ValidationFailed = true
+		c.fail("InvalidPath", field+".path", e.Path, "Invalid value '%s' provided for endpoint %s. Please provide a valid path.", e.Path, e.Name)
 	}
 }
 
-func (e *EndpointConfig) validateBackend(c *Config) {
-	if e.Path == "" {
-		log.Printf("\t - Error.InvalidBackend :: Invalid value '%s' provided for endpoint %s. Please provide a valid http or https backend.\n", e.Backend, e.Name)
-		c.ValidationFailed = true
+func (e *EndpointConfig) validateBackend(field string, c *Config) {
+	if e.Backend == "" {
+		c.fail("InvalidBackend", field+".backend", e.Backend, "Invalid value '%s' provided for endpoint %s. Please provide a valid http or https backend.", e.Backend, e.Name)
 	}
 }
 
@@ -277,9 +1168,70 @@ func (conf *Config) optimise() {
 }
 
 func (gc *GatewayConfig) optimise(c *Config) {
+	gc.ShutdownTimeoutDuration = 30 * time.Second
+	if gc.ShutdownTimeoutString != "" {
+		gc.ShutdownTimeoutString = strings.ToUpper(gc.ShutdownTimeoutString)
+		gc.ShutdownTimeoutDuration = stringToDuration(gc.ShutdownTimeoutString)
+	}
+
+	gc.LogLevel = strings.ToUpper(gc.LogLevel)
+	if gc.LogLevel == "" {
+		gc.LogLevel = LogLevelInfo
+	}
+	gc.LogOutput = strings.ToUpper(gc.LogOutput)
+	if gc.LogOutput == "" {
+		gc.LogOutput = LogOutputStdio
+	}
+	if gc.LogOutput == LogOutputFile {
+		gc.LogFile.optimise()
+	}
+	if gc.LogOutput == LogOutputKafka {
+		gc.LogCredentials.Kafka.optimise(gc)
+	}
+	if gc.LogOutput == LogOutputELK {
+		gc.LogCredentials.ELK.optimise()
+	}
+	gc.AccessLog.optimise()
+	gc.Tracing.optimise()
+
+	if gc.RequestIDHeader == "" {
+		gc.RequestIDHeader = "X-Request-ID"
+	}
+
 	gc.RateLimit.optimise(CFLevelGateway, c)
+	gc.RateLimit.Store.optimise(gc)
+	for i := range gc.RateLimits {
+		gc.RateLimits[i].optimise(CFLevelGateway, c)
+	}
+
+	gc.MaxBodySizeBytes = 10 << 20
+	if gc.MaxBodySizeString != "" {
+		gc.MaxBodySizeString = strings.ToUpper(gc.MaxBodySizeString)
+		gc.MaxBodySizeBytes = stringToSize(gc.MaxBodySizeString)
+	}
+
+	gc.CORS.optimise()
+	gc.Auth.optimise(gc)
+	for i := range gc.Middleware {
+		gc.Middleware[i].optimise()
+	}
+
+	gc.TrustedProxyNets = nil
+	for _, proxy := range gc.TrustedProxies {
+		ipNet, _ := parseIPNet(proxy)
+		gc.TrustedProxyNets = append(gc.TrustedProxyNets, ipNet)
+	}
+
 	for i, ep := range gc.Endpoints {
 		ep.RateLimit.optimise(CFLevelEndpoint, c)
+		for j := range ep.RateLimits {
+			ep.RateLimits[j].optimise(CFLevelEndpoint, c)
+		}
+		ep.CORS.optimise()
+		ep.Auth.optimise(gc)
+		for j := range ep.Middleware {
+			ep.Middleware[j].optimise()
+		}
 		// to maintain consistency with method names provided by net/http package
 		ep.Method = strings.ToUpper(ep.Method)
 		gc.Endpoints[i] = ep
@@ -297,6 +1249,8 @@ func (rl *RateLimiterConfig) optimise(rlType string, c *Config) {
 	rl.WindowDuration = stringToDuration(rl.WindowString)
 	rl.PenaltyDuration = stringToDuration(rl.PenaltyString)
 
+	rl.KeyType, rl.KeyName = splitKeyBy(rl.KeyBy)
+
 	// if the penalty duration is 0 seconds,
 	// set the penalty enabled flag to false
 	if rl.PenaltyDuration != TimeNil {
@@ -304,6 +1258,241 @@ func (rl *RateLimiterConfig) optimise(rlType string, c *Config) {
 	}
 }
 
+func (cors *CORSConfig) optimise() {
+	if !cors.Enabled {
+		return
+	}
+
+	// Origins are case insensitive and methods are upper
+	// case, headers are canonicalised when matched
+	for i, domain := range cors.AllowedDomains {
+		cors.AllowedDomains[i] = strings.ToLower(domain)
+	}
+	for i, method := range cors.AllowedMethods {
+		cors.AllowedMethods[i] = strings.ToUpper(method)
+	}
+
+	if cors.MaxAgeString != "" {
+		cors.MaxAgeString = strings.ToUpper(cors.MaxAgeString)
+		cors.MaxAgeDuration = stringToDuration(cors.MaxAgeString)
+	}
+}
+
+func (auth *AuthConfig) optimise(gc *GatewayConfig) {
+	if !auth.Enabled {
+		return
+	}
+
+	auth.Type = strings.ToUpper(auth.Type)
+
+	if auth.ConsumerHeader == "" {
+		auth.ConsumerHeader = "X-Consumer"
+	}
+	auth.ConsumerHeader = textproto.CanonicalMIMEHeaderKey(auth.ConsumerHeader)
+
+	if auth.Type == AuthTypeAPIKey && auth.APIKey.Header == "" {
+		auth.APIKey.Header = "X-Api-Key"
+	}
+
+	if auth.Type == AuthTypeJWT {
+		auth.JWT.optimise()
+	}
+
+	if auth.Type == AuthTypeHMAC {
+		auth.HMAC.optimise(gc)
+	}
+}
+
+func (hmac *HMACAuthConfig) optimise(gc *GatewayConfig) {
+	if hmac.TokenHeader == "" {
+		hmac.TokenHeader = "X-Api-Token"
+	}
+	if hmac.TimestampHeader == "" {
+		hmac.TimestampHeader = "X-Timestamp"
+	}
+	if hmac.SignatureHeader == "" {
+		hmac.SignatureHeader = "X-Signature"
+	}
+
+	hmac.ClockSkewDuration = 5 * time.Minute
+	if hmac.ClockSkewString != "" {
+		hmac.ClockSkewString = strings.ToUpper(hmac.ClockSkewString)
+		hmac.ClockSkewDuration = stringToDuration(hmac.ClockSkewString)
+	}
+
+	hmac.MaxBodySizeBytes = gc.MaxBodySizeBytes
+}
+
+func (jwt *JWTAuthConfig) optimise() {
+	for i, alg := range jwt.Algorithms {
+		jwt.Algorithms[i] = strings.ToUpper(alg)
+	}
+
+	// Without an explicit list, allow the algorithms
+	// matching the kinds of keys that have been provided
+	if len(jwt.Algorithms) == 0 {
+		if jwt.Secret != "" || jwt.JWKSFilePath != "" {
+			jwt.Algorithms = append(jwt.Algorithms, JWTAlgHS256)
+		}
+		if jwt.PublicKeyFilePath != "" || jwt.JWKSFilePath != "" {
+			jwt.Algorithms = append(jwt.Algorithms, JWTAlgRS256, JWTAlgES256)
+		}
+	}
+
+	if jwt.ConsumerClaim == "" {
+		jwt.ConsumerClaim = "sub"
+	}
+
+	for claim, header := range jwt.ForwardClaims {
+		jwt.ForwardClaims[claim] = textproto.CanonicalMIMEHeaderKey(header)
+	}
+
+	if jwt.LeewayString != "" {
+		jwt.LeewayString = strings.ToUpper(jwt.LeewayString)
+		jwt.LeewayDuration = stringToDuration(jwt.LeewayString)
+	}
+}
+
+func (mw *MiddlewareConfig) optimise() {
+	mw.HeaderPrefix = textproto.CanonicalMIMEHeaderKey(mw.HeaderPrefix)
+	for i, header := range mw.MutableHeaders {
+		mw.MutableHeaders[i] = textproto.CanonicalMIMEHeaderKey(header)
+	}
+	for i, header := range mw.ForwardHeaders {
+		mw.ForwardHeaders[i] = textproto.CanonicalMIMEHeaderKey(header)
+	}
+
+	mw.OnFailure = strings.ToUpper(mw.OnFailure)
+	if mw.OnFailure == "" {
+		mw.OnFailure = MWFailClosed
+	}
+
+	mw.TimeoutDuration = 5 * time.Second
+	if mw.TimeoutString != "" {
+		mw.TimeoutString = strings.ToUpper(mw.TimeoutString)
+		mw.TimeoutDuration = stringToDuration(mw.TimeoutString)
+	}
+}
+
+func (st *RateLimitStoreConfig) optimise(gc *GatewayConfig) {
+	st.Type = strings.ToUpper(st.Type)
+	if st.Type == "" {
+		st.Type = RLStoreMemory
+	}
+
+	// Namespace the keys by gateway so that multiple gateways
+	// can share the same redis server without interfering
+	if st.KeyPrefix == "" {
+		st.KeyPrefix = fmt.Sprintf("hodor:%d", gc.ID)
+	}
+
+	if st.PoolSize == 0 {
+		st.PoolSize = 10
+	}
+
+	st.TimeoutDuration = time.Second
+	if st.TimeoutString != "" {
+		st.TimeoutDuration = stringToDuration(strings.ToUpper(st.TimeoutString))
+	}
+}
+
+func (al *AccessLogConfig) optimise() {
+	al.Format = strings.ToUpper(al.Format)
+	if al.Format == "" {
+		al.Format = AccessLogJSON
+	}
+
+	al.Output = strings.ToUpper(al.Output)
+	if al.Output == LogOutputFile {
+		al.File.optimise()
+	}
+}
+
+func (tc *TracingConfig) optimise() {
+	if tc.ServiceName == "" {
+		tc.ServiceName = "hodor"
+	}
+	if tc.BufferSize == 0 {
+		tc.BufferSize = 2048
+	}
+	if tc.BatchSize == 0 {
+		tc.BatchSize = 512
+	}
+
+	tc.FlushIntervalDuration = 5 * time.Second
+	if tc.FlushIntervalString != "" {
+		tc.FlushIntervalDuration = stringToDuration(strings.ToUpper(tc.FlushIntervalString))
+	}
+
+	tc.TimeoutDuration = 10 * time.Second
+	if tc.TimeoutString != "" {
+		tc.TimeoutDuration = stringToDuration(strings.ToUpper(tc.TimeoutString))
+	}
+}
+
+func (lf *LogFileConfig) optimise() {
+	if lf.MaxArchives == 0 {
+		lf.MaxArchives = 7
+	}
+
+	lf.MaxSizeBytes = 100 << 20
+	if lf.MaxSizeString != "" {
+		lf.MaxSizeString = strings.ToUpper(lf.MaxSizeString)
+		lf.MaxSizeBytes = stringToSize(lf.MaxSizeString)
+	}
+}
+
+// splitKeyBy breaks a rate limiter key down into its type and name.
+// Ex: 'header:X-Api-Key' is broken down into 'header' and 'X-Api-Key'.
+func splitKeyBy(keyBy string) (string, string) {
+	if keyBy == "" {
+		return RLKeyAll, ""
+	}
+
+	parts := strings.SplitN(keyBy, ":", 2)
+	keyType := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return keyType, ""
+	}
+	return keyType, parts[1]
+}
+
+// hasPathParam checks whether an httprouter path contains a named
+// (':name') or catch-all ('*name') parameter with the given name.
+func hasPathParam(path string, name string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == ":"+name || segment == "*"+name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIPNet parses an IP address or a CIDR range into an IP network.
+// A single IP address is treated as a network containing only itself.
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address '%s'", s)
+	}
+
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	} else {
+		ip = ip.To4()
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// stringToDuration converts a duration string of format <length><unit>
+// into a time.Duration. Strings that fail to parse convert to 0, their
+// format must have been validated before.
 func stringToDuration(s string) time.Duration {
 	// * Users can provide a '-' if they do not wish to levy
 	// * any penalty on the clients for exceeding rate limits.
@@ -314,15 +1503,15 @@ func stringToDuration(s string) time.Duration {
 
 	matches := durationRegex.FindStringSubmatch(s)
 	if len(matches) == 0 {
-		log.Fatalln("Error while parsing duration string")
+		return TimeNil
 	}
 
 	t, err := strconv.Atoi(matches[1])
 	if err != nil {
-		log.Fatalln("Error while parsing duration string")
+		return TimeNil
 	}
 
-	switch matches[2] {
+	switch strings.ToUpper(matches[2]) {
 	case TimeUnitSecond:
 		return time.Duration(t) * time.Second
 
@@ -337,58 +1526,28 @@ func stringToDuration(s string) time.Duration {
 	}
 }
 
-// ResolveConfigFilePath loads the API Gateway config from a JSON file. It expects
-// the filepath to be provided using the "-config" flag when the application is
-// started. If no such flag is provided, it assumes a default path
-// './config.json'. If a config file is not found at the provided filepath, it
-// logs a Fatal error and the program exits.
-func ResolveConfigFilePath() string {
-	configFilePath := flag.String(
-		"config",
-		"./config.yml",
-		"Absolute or relative path of the API Gateway configuration file",
-	)
-
-	flag.Parse()
-
-	// If a relative path is provided, resolve it to an absolute path
-	absConfigPath := helpers.FilePathHelper.GetFullPath(*configFilePath)
-
-	// Raise a Fatal error if the filepath is not valid
-	if !helpers.FilePathHelper.IsValidPath(absConfigPath) {
-		log.Fatalf(
-			"Invalid filepath [%s] provided for -config. Please make sure the file exists and is valid.",
-			*configFilePath,
-		)
-	}
-
-	return absConfigPath
-}
-
-// LoadConfig function loads the configuration from the config filepath
-// provided by the user. It performs 5 crucial steps:
-// 1. Resolve the config filepath provided using the -config flag.
-// 2. Scan the file and convert it into a byte slice
-// 3. Parse the byte slice into an instance of type Config
-// 4. Validate the values loaded into the instance
-// 5. Optimise the instance
-func LoadConfig() Config {
-	once.Do(func() {
-		configPath := ResolveConfigFilePath()
-		filecontent := Conf.scan(configPath)
-		Conf.parse(filecontent)
-		Conf.validate()
-		Conf.optimise()
-	})
-	return Conf
-}
-
-// GetConfig function returns the global instance of type Config
-// If it hasn't already been initialised/loaded from file, the
-// Load() function is called to do so and return the config
-func GetConfig() Config {
-	if !Conf.Initialised {
-		return LoadConfig()
-	}
-	return Conf
+// stringToSize converts a size string of format <size><unit> into bytes.
+// Strings that fail to parse convert to 0, their format must have been
+// validated before.
+func stringToSize(s string) int64 {
+	matches := sizeRegex.FindStringSubmatch(s)
+	if len(matches) == 0 {
+		return 0
+	}
+
+	size, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	switch strings.ToUpper(matches[2]) {
+	case "KB":
+		return size << 10
+
+	case "MB":
+		return size << 20
+
+	default:
+		return size << 30
+	}
 }
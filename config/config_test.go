@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testConfig is a minimal valid config, with a gateway rate limit whose
+// window is provided as a format argument.
+const testConfig = `gateway:
+  id: 1
+  name: "test"
+  port: ":8080"
+  rate_limit:
+    enable: true
+    requests: 10
+    window: %s
+    penalty: "-"
+  endpoints:
+  - id: 1
+    name: "users"
+    method: GET
+    path: /users
+    backend: "http://127.0.0.1:8081"
+`
+
+// errorCodes lists the codes of the validation errors returned by Parse.
+func errorCodes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %T :: %s", err, err)
+	}
+
+	codes := make([]string, len(errs))
+	for i, e := range errs {
+		codes[i] = e.Code
+	}
+	return codes
+}
+
+func TestRateLimitWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		want   time.Duration
+		valid  bool
+	}{
+		{"10S", 10 * time.Second, true},
+		{"10s", 10 * time.Second, true},
+		{"2M", 2 * time.Minute, true},
+		{"1h", time.Hour, true},
+		{"0S", 0, false},
+		{"00M", 0, false},
+		{"-1S", 0, false},
+		{"a10S", 0, false},
+		{"10Sx", 0, false},
+		{"10", 0, false},
+		{"S", 0, false},
+		{`""`, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.window, func(t *testing.T) {
+			conf, err := Parse([]byte(fmt.Sprintf(testConfig, test.window)))
+			codes := errorCodes(t, err)
+			if !test.valid {
+				if len(codes) != 1 || codes[0] != "InvalidRateLimitWindow" {
+					t.Fatalf("expected InvalidRateLimitWindow, got %v", codes)
+				}
+				return
+			}
+
+			if len(codes) > 0 {
+				t.Fatalf("expected no errors, got %v", codes)
+			}
+			if got := conf.Gateway.RateLimit.WindowDuration; got != test.want {
+				t.Errorf("expected a window of %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRegistered(t *testing.T) {
+	lookup := WithMiddlewareLookup(func(name string) bool { return name == "require-tenant" })
+
+	tests := []struct {
+		name    string
+		options []Option
+		codes   []string
+	}{
+		{"require-tenant", []Option{lookup}, nil},
+		{"require-tenants", []Option{lookup}, []string{"UnknownMiddleware"}},
+		{"require-tenants", nil, nil},
+	}
+
+	for _, test := range tests {
+		content := fmt.Sprintf(testConfig, "10S") + fmt.Sprintf("  middleware:\n  - name: %q\n", test.name)
+		_, err := Parse([]byte(content), test.options...)
+		codes := errorCodes(t, err)
+		if fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("middleware '%s' :: expected %v, got %v", test.name, test.codes, codes)
+		}
+	}
+}
+
+func TestTracingDurations(t *testing.T) {
+	tests := []struct {
+		flushInterval string
+		timeout       string
+		fields        []string
+	}{
+		{"", "", nil},
+		{"5S", "10s", nil},
+		{"0S", "10S", []string{"gateway.tracing.flush_interval"}},
+		{"5S", "00M", []string{"gateway.tracing.timeout"}},
+		{"5", "S", []string{"gateway.tracing.flush_interval", "gateway.tracing.timeout"}},
+	}
+
+	for _, test := range tests {
+		c := &Config{}
+		tc := &TracingConfig{Enabled: true, Endpoint: "http://otel-collector:4318/v1/traces", FlushIntervalString: test.flushInterval, TimeoutString: test.timeout}
+		tc.validate(c)
+
+		var fields []string
+		for _, err := range c.Errors {
+			if err.Code != "InvalidTracingDuration" {
+				t.Errorf("expected InvalidTracingDuration, got %s", err.Code)
+			}
+			fields = append(fields, err.Field)
+		}
+		if fmt.Sprint(fields) != fmt.Sprint(test.fields) {
+			t.Errorf("flush interval '%s' and timeout '%s' :: expected errors for %v, got %v", test.flushInterval, test.timeout, test.fields, fields)
+		}
+	}
+}
+
+func TestRateLimitStoreTimeout(t *testing.T) {
+	tests := []struct {
+		timeout string
+		valid   bool
+	}{
+		{"", true},
+		{"1S", true},
+		{"500s", true},
+		{"0S", false},
+		{"00M", false},
+		{"1", false},
+	}
+
+	for _, test := range tests {
+		c := &Config{}
+		st := &RateLimitStoreConfig{Type: RLStoreRedis, Address: "redis:6379", TimeoutString: test.timeout}
+		st.validate(c)
+
+		codes := make([]string, len(c.Errors))
+		for i, err := range c.Errors {
+			codes[i] = err.Code
+		}
+		if test.valid != (len(codes) == 0) || (!test.valid && fmt.Sprint(codes) != "[InvalidRateLimitStoreTimeout]") {
+			t.Errorf("timeout '%s' :: expected valid %t, got %v", test.timeout, test.valid, codes)
+		}
+	}
+}
+
+func TestMiddlewareTimeoutAndMaxBodySize(t *testing.T) {
+	tests := []struct {
+		content string
+		codes   []string
+	}{
+		{"  middleware:\n  - url: \"http://mw\"\n    timeout: \"2S\"\n  max_body_size: \"1KB\"\n", nil},
+		{"  middleware:\n  - url: \"http://mw\"\n    timeout: \"0S\"\n", []string{"InvalidMiddlewareTimeout"}},
+		{"  max_body_size: \"0MB\"\n", []string{"InvalidMaxBodySize"}},
+		{"  max_body_size: \"10\"\n", []string{"InvalidMaxBodySize"}},
+	}
+
+	for _, test := range tests {
+		conf, err := Parse([]byte(fmt.Sprintf(testConfig, "10S") + test.content))
+		codes := errorCodes(t, err)
+		if fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("%q :: expected %v, got %v", test.content, test.codes, codes)
+		}
+		if err == nil && conf.Gateway.MaxBodySizeBytes != 1<<10 {
+			t.Errorf("expected a max body size of 1KB, got %d", conf.Gateway.MaxBodySizeBytes)
+		}
+	}
+}
+
+func TestMiddlewareMutableHeaders(t *testing.T) {
+	tests := []struct {
+		content string
+		codes   []string
+	}{
+		{"  middleware:\n  - url: \"http://mw\"\n    header_prefix: \"x-hodor-\"\n    mutable_headers: [\"x-user-id\"]\n", nil},
+		{"  middleware:\n  - url: \"http://mw\"\n    mutable_headers: [\"X-User-Id\"]\n", []string{"InvalidMiddlewareMutableHeaders"}},
+		{"  middleware:\n  - url: \"http://mw\"\n    header_prefix: \"X-Hodor-\"\n    mutable_headers: [\"X User\"]\n", []string{"InvalidMiddlewareMutableHeader"}},
+	}
+
+	for _, test := range tests {
+		conf, err := Parse([]byte(fmt.Sprintf(testConfig, "10S") + test.content))
+		codes := errorCodes(t, err)
+		if fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("%q :: expected %v, got %v", test.content, test.codes, codes)
+		}
+		if err == nil {
+			mw := conf.Gateway.Middleware[0]
+			if mw.HeaderPrefix != "X-Hodor-" || mw.MutableHeaders[0] != "X-User-Id" {
+				t.Errorf("expected canonical header names, got %q and %q", mw.HeaderPrefix, mw.MutableHeaders[0])
+			}
+		}
+	}
+}
+
+func TestHMACClockSkewAndMaxBodySize(t *testing.T) {
+	dir := tempDir(t)
+	writeFiles(t, dir, map[string]string{"consumers.yml": "consumers: []\n"})
+	auth := "  consumers_file: %q\n  max_body_size: \"1KB\"\n  auth:\n    enable: true\n    type: HMAC\n    hmac:\n      clock_skew: %q\n"
+
+	tests := []struct {
+		clockSkew string
+		codes     []string
+	}{
+		{"30S", nil},
+		{"0S", []string{"InvalidHMACClockSkew"}},
+		{"30", []string{"InvalidHMACClockSkew"}},
+	}
+
+	for _, test := range tests {
+		content := fmt.Sprintf(testConfig, "10S") + fmt.Sprintf(auth, filepath.Join(dir, "consumers.yml"), test.clockSkew)
+		conf, err := Parse([]byte(content))
+		codes := errorCodes(t, err)
+		if fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("clock skew '%s' :: expected %v, got %v", test.clockSkew, test.codes, codes)
+		}
+		if err == nil && conf.Gateway.Auth.HMAC.MaxBodySizeBytes != 1<<10 {
+			t.Errorf("expected HMAC bodies to be limited to the max body size, got %d", conf.Gateway.Auth.HMAC.MaxBodySizeBytes)
+		}
+	}
+}
+
+func TestLogFileMaxSize(t *testing.T) {
+	logFile := "  log_output: FILE\n  log_file:\n    path: %q\n    max_size: %q\n"
+
+	tests := []struct {
+		maxSize string
+		codes   []string
+	}{
+		{"1KB", nil},
+		{"0MB", []string{"InvalidLogFileMaxSize"}},
+		{"100", []string{"InvalidLogFileMaxSize"}},
+	}
+
+	for _, test := range tests {
+		content := fmt.Sprintf(testConfig, "10S") + fmt.Sprintf(logFile, filepath.Join(tempDir(t), "hodor.log"), test.maxSize)
+		conf, err := Parse([]byte(content))
+		codes := errorCodes(t, err)
+		if fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("max size '%s' :: expected %v, got %v", test.maxSize, test.codes, codes)
+		}
+		if err == nil && conf.Gateway.LogFile.MaxSizeBytes != 1<<10 {
+			t.Errorf("expected a max size of 1KB, got %d", conf.Gateway.LogFile.MaxSizeBytes)
+		}
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		timeout string
+		codes   []string
+	}{
+		{"10S", nil},
+		{"0S", []string{"InvalidShutdownTimeout"}},
+		{"10", []string{"InvalidShutdownTimeout"}},
+	}
+
+	for _, test := range tests {
+		conf, err := Parse([]byte(fmt.Sprintf(testConfig, "10S") + fmt.Sprintf("  shutdown_timeout: %q\n", test.timeout)))
+		codes := errorCodes(t, err)
+		if fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("shutdown timeout '%s' :: expected %v, got %v", test.timeout, test.codes, codes)
+		}
+		if err == nil && conf.Gateway.ShutdownTimeoutDuration != 10*time.Second {
+			t.Errorf("expected a shutdown timeout of 10s, got %s", conf.Gateway.ShutdownTimeoutDuration)
+		}
+	}
+}
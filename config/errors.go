@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is a rule of the configuration broken by the config.
+// Code identifies the rule, Ex: InvalidPort. Field is the path of the
+// offending field from the root of the config file, Ex:
+// gateway.endpoints[2].method, and Value the value it has been given.
+// Fields of the log credentials file are under log_credentials.
+// File, Line and Column locate the field, or its closest ancestor present,
+// in the file it has been read from. They are left empty when it cannot be
+// located.
+type ValidationError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+func (ve *ValidationError) Error() string {
+	return "Error." + ve.Code + " :: " + ve.Message
+}
+
+// ValidationErrors collects all the rules broken by a config. It is the
+// error returned by Load and Parse when a config fails validation.
+type ValidationErrors []*ValidationError
+
+func (ve ValidationErrors) Error() string {
+	lines := make([]string, len(ve))
+	for i, err := range ve {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fail records that the config breaks a rule. The message is formatted
+// with the arguments in the manner of fmt.Sprintf.
+func (conf *Config) fail(code string, field string, value interface{}, format string, args ...interface{}) {
+	conf.Errors = append(conf.Errors, &ValidationError{
+		Code:    code,
+		Field:   field,
+		Value:   fmt.Sprint(value),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// keyedString and keyedInt pair a value with its YAML key, so that fields
+// validated by the same rule can be reported under their own path.
+type keyedString struct {
+	key   string
+	value string
+}
+
+type keyedInt struct {
+	key   string
+	value int
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/saidmithilesh/hodor/helpers"
+)
+
+// gatewayFileNames are the names the gateway file is looked up by when
+// the config is loaded from a directory.
+var gatewayFileNames = []string{"config.yml", "config.yaml"}
+
+// EndpointsFile encapsulates the content of a file included by the gateway
+// config. Its endpoints are added to those of the gateway. PathPrefix is
+// prepended to the path of each of them, and Backend is the backend of
+// those which do not provide one.
+type EndpointsFile struct {
+	PathPrefix string           `yaml:"path_prefix"`
+	Backend    string           `yaml:"backend"`
+	Endpoints  []EndpointConfig `yaml:"endpoints"`
+}
+
+// gatewayFilePath returns the path of the gateway file of a config, given
+// the path of the file or of the directory containing it.
+func gatewayFilePath(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return path, nil
+	}
+
+	for _, name := range gatewayFileNames {
+		file := filepath.Join(path, name)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("the config directory '%s' contains no gateway file, Ex: %s", path, gatewayFileNames[0])
+}
+
+// Files lists the files the config is read from: the gateway file followed
+// by the files it includes, as they are on disk at the time of the call.
+func (conf *Config) Files() []string {
+	if conf.gatewayFile == "" {
+		return nil
+	}
+	files, _ := conf.includedFiles()
+	return append([]string{conf.gatewayFile}, files...)
+}
+
+// includedFiles resolves the include patterns of the gateway file into
+// the files they match, in order. The patterns are globs relative to the
+// directory of the gateway file. When the config is loaded from a
+// directory and the gateway file has no include patterns, every YAML file
+// of the directory is included, apart from the consumers and log
+// credentials files. The errors returned are those of the patterns at the
+// same index, nil for the patterns which are valid and match a file or
+// contain a wildcard.
+func (conf *Config) includedFiles() ([]string, []error) {
+	dir := filepath.Dir(conf.gatewayFile)
+	if conf.gatewayFile == "" {
+		dir, _ = os.Getwd()
+	}
+
+	patterns := conf.Gateway.Include
+	exclude := map[string]bool{conf.gatewayFile: true}
+	if len(patterns) == 0 && conf.ConfigFilePath != "" && conf.ConfigFilePath != conf.gatewayFile {
+		patterns = []string{"*.yml", "*.yaml"}
+		for _, path := range []string{conf.Gateway.ConsumersFilePath, conf.Gateway.LogCredentialsFilePath} {
+			if path != "" {
+				exclude[helpers.FilePathHelper.GetFullPath(path)] = true
+			}
+		}
+	}
+
+	var files []string
+	errs := make([]error, len(patterns))
+	for i, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+			errs[i] = fmt.Errorf("no such file '%s'", pattern)
+			continue
+		}
+
+		sort.Strings(matches)
+		for _, file := range matches {
+			if info, err := os.Stat(file); err != nil || info.IsDir() || exclude[file] {
+				continue
+			}
+			exclude[file] = true
+			files = append(files, file)
+		}
+	}
+	return files, errs
+}
+
+// include adds the endpoints of the files included by the gateway file to
+// the gateway config.
+func (conf *Config) include() {
+	files, errs := conf.includedFiles()
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		pattern := conf.Gateway.Include[i]
+		conf.fail("InvalidInclude", fmt.Sprintf("gateway.include[%d]", i), pattern, "Invalid value '%s' provided for include :: %s", pattern, err)
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			conf.fail("InvalidInclude", "gateway.include", file, "Error while reading included file '%s' :: %s", file, err)
+			continue
+		}
+		conf.includeFile(file, content)
+	}
+}
+
+// includeFile adds the endpoints of an included file to the gateway
+// config. The errors found in the file itself are located in it. Keys
+// other than those of EndpointsFile are rejected, so that a misspelt key
+// or an unrelated YAML file does not go unnoticed.
+func (conf *Config) includeFile(file string, content []byte) {
+	var ef EndpointsFile
+	resolved, errs := resolveTypedReferences(content, reflect.TypeOf(ef), "")
+	if err := yaml.UnmarshalStrict(resolved, &ef); err != nil {
+		for _, err := range yamlErrors(err) {
+			err.File = file
+			conf.Errors = append(conf.Errors, err)
+		}
+		return
+	}
+
+	for _, err := range append(errs, interpolate(&ef, "")...) {
+		err.File = file
+		err.Line, err.Column = locateField(content, err.Field)
+		conf.Errors = append(conf.Errors, err)
+	}
+
+	if ef.PathPrefix != "" && (!strings.HasPrefix(ef.PathPrefix, "/") || strings.ContainsAny(ef.PathPrefix, ":*")) {
+		line, column := locateField(content, "path_prefix")
+		conf.Errors = append(conf.Errors, &ValidationError{
+			Code:    "InvalidPathPrefix",
+			Field:   "path_prefix",
+			Value:   ef.PathPrefix,
+			Message: fmt.Sprintf("Invalid value '%s' provided for path prefix of included file '%s'. Please provide a path starting with '/' and without parameters. Ex: /payments", ef.PathPrefix, file),
+			File:    file,
+			Line:    line,
+			Column:  column,
+		})
+	}
+
+	prefix := strings.TrimRight(ef.PathPrefix, "/")
+	for i, e := range ef.Endpoints {
+		if e.Path != "" {
+			e.Path = prefix + e.Path
+		}
+		if e.Backend == "" {
+			e.Backend = ef.Backend
+		}
+
+		conf.sources = append(conf.sources, source{
+			prefix:  fmt.Sprintf("gateway.endpoints[%d]", len(conf.Gateway.Endpoints)),
+			root:    fmt.Sprintf("endpoints[%d]", i),
+			file:    file,
+			content: content,
+		})
+		conf.Gateway.Endpoints = append(conf.Gateway.Endpoints, e)
+	}
+}
+
+// endpointFile returns the file the endpoint at index i has been read
+// from, empty if it is not known.
+func (conf *Config) endpointFile(i int) string {
+	if src := conf.sourceOf(fmt.Sprintf("gateway.endpoints[%d]", i)); src != nil && src.file != "" {
+		return src.file
+	}
+	return conf.gatewayFile
+}
+
+// describeEndpoint names the endpoint at index i in the validation errors
+// about the endpoint at index other, along with the file it has been read
+// from if the other endpoint has been read from another file.
+func (conf *Config) describeEndpoint(i int, other int) string {
+	e := &conf.Gateway.Endpoints[i]
+	description := fmt.Sprintf("%s (id %d)", e.Name, e.ID)
+	if file := conf.endpointFile(i); file != conf.endpointFile(other) {
+		if file == "" {
+			return description + " of the gateway file"
+		}
+		description += fmt.Sprintf(" of '%s'", file)
+	}
+	return description
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncludeStrict(t *testing.T) {
+	dir := tempDir(t)
+	writeFiles(t, dir, map[string]string{
+		"config.yml": fmt.Sprintf(testConfig, "10S"),
+		"payments.yml": `backend: "http://127.0.0.1:8082"
+endpoint:
+- id: 2
+  name: "payments"
+  method: GET
+  path: /payments
+`,
+		"docker-compose.yml": `services:
+  hodor:
+    image: hodor
+`,
+	})
+
+	_, err := Load(dir)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	var got []string
+	for _, e := range errs {
+		got = append(got, fmt.Sprintf("%s %s:%d", e.Code, filepath.Base(e.File), e.Line))
+	}
+	want := []string{"InvalidYAML docker-compose.yml:1", "InvalidYAML payments.yml:2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestIncludeErrorsOrder(t *testing.T) {
+	dir := tempDir(t)
+	writeFiles(t, dir, map[string]string{
+		"config.yml": fmt.Sprintf(testConfig, "10S") + `  include:
+  - "a.yml"
+  - "teams/*.yml"
+  - "b.yml"
+  - "[.yml"
+  - "c.yml"
+`,
+	})
+
+	want := "[gateway.include[0] gateway.include[2] gateway.include[3] gateway.include[4]]"
+	for i := 0; i < 10; i++ {
+		_, err := Load(dir)
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			t.Fatalf("expected validation errors, got %v", err)
+		}
+
+		var fields []string
+		for _, e := range errs {
+			if e.Code != "InvalidInclude" {
+				t.Fatalf("expected InvalidInclude, got %s", e)
+			}
+			fields = append(fields, e.Field)
+		}
+		if fmt.Sprint(fields) != want {
+			t.Fatalf("expected errors for %s, got %v", want, fields)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/saidmithilesh/hodor/helpers"
+)
+
+// Matches references within the values of the config: ${ENV_VAR},
+// ${ENV_VAR:-default} and ${file:/path/to/file}. '$${' is an escaped '${'.
+// A reference missing its closing brace runs to the end of the value.
+var (
+	referenceRegex = regexp.MustCompile(`\$\$\{|\$\{([^}]*)(\})?`)
+	envVarRegex    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	scalarRegex    = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+)
+
+// interpolate replaces the references within the string values of a
+// decoded config file with their values. v is a pointer to the struct the
+// file has been decoded into, and field the path of that struct from the
+// root of the config. Values are walked by the path of their YAML keys, so
+// that the references which cannot be resolved are reported as validation
+// errors about the field they are in. They are replaced with an empty
+// string. Since values are replaced after the file has been parsed, they
+// are taken as they are, whatever characters they contain.
+func interpolate(v interface{}, field string) ValidationErrors {
+	var errs ValidationErrors
+	interpolateValue(reflect.ValueOf(v), field, &errs)
+	return errs
+}
+
+// resolveTypedReferences resolves the references making up the whole value
+// of the number and boolean fields of a config file, Ex: requests: ${RL},
+// which the file cannot be decoded into before they are. t is the type of
+// the struct the file is decoded into, and field the path of that struct
+// from the root of the config. The references are replaced with their
+// values within the content returned. Those values must be plain numbers
+// or booleans, so that they take up the line of the reference and cannot
+// change the structure of the file. The references which cannot be
+// resolved are replaced with null, leaving the field unset.
+func resolveTypedReferences(content []byte, t reflect.Type, field string) ([]byte, ValidationErrors) {
+	var tree interface{}
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		// The error is reported when the content is decoded into the config
+		return content, nil
+	}
+
+	values := make(map[string]string)
+	var errs ValidationErrors
+	collectTypedReferences(tree, t, field, values, &errs)
+	if len(values) == 0 {
+		return content, errs
+	}
+
+	return referenceRegex.ReplaceAllFunc(content, func(reference []byte) []byte {
+		if value, ok := values[string(reference)]; ok {
+			return []byte(value)
+		}
+		return reference
+	}), errs
+}
+
+func collectTypedReferences(node interface{}, t reflect.Type, field string, values map[string]string, errs *ValidationErrors) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		collectTypedReferences(node, t.Elem(), field, values, errs)
+
+	case reflect.Struct:
+		m, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("yaml")
+			if f.PkgPath != "" || tag == "" || tag == "-" {
+				continue
+			}
+
+			name := strings.Split(tag, ",")[0]
+			if strings.Contains(tag, ",inline") {
+				collectTypedReferences(node, f.Type, field, values, errs)
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if value, ok := m[name]; ok {
+				collectTypedReferences(value, f.Type, joinField(field, name), values, errs)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		s, ok := node.([]interface{})
+		if !ok {
+			return
+		}
+		for i, value := range s {
+			collectTypedReferences(value, t.Elem(), fmt.Sprintf("%s[%d]", field, i), values, errs)
+		}
+
+	case reflect.Map:
+		m, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		keys := make([]interface{}, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			collectTypedReferences(m[key], t.Elem(), joinField(field, fmt.Sprint(key)), values, errs)
+		}
+
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		reference, ok := node.(string)
+		if !ok || reference == "$${" || referenceRegex.FindString(reference) != reference {
+			return
+		}
+		if _, ok := values[reference]; ok {
+			return
+		}
+
+		value, err := resolveReference(reference)
+		value = strings.TrimSpace(value)
+		if err == nil && (!scalarRegex.MatchString(value) || yaml.Unmarshal([]byte(value), reflect.New(t).Interface()) != nil) {
+			err = referenceError("InvalidReference", reference, "Invalid value '%s' provided by reference '%s'. Please provide a valid %s", value, reference, typeName(t))
+		}
+		if err != nil {
+			err.Field = field
+			*errs = append(*errs, err)
+			value = "null"
+		}
+		values[reference] = value
+	}
+}
+
+// typeName describes the values of a number or boolean type.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean, Ex: true"
+	case reflect.Float32, reflect.Float64:
+		return "number, Ex: 0.5"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "positive integer, Ex: 10"
+	default:
+		return "integer, Ex: 10"
+	}
+}
+
+func interpolateValue(v reflect.Value, field string, errs *ValidationErrors) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			interpolateValue(v.Elem(), field, errs)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("yaml")
+			if f.PkgPath != "" || tag == "" || tag == "-" {
+				continue
+			}
+
+			name := strings.Split(tag, ",")[0]
+			if strings.Contains(tag, ",inline") {
+				interpolateValue(v.Field(i), field, errs)
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			interpolateValue(v.Field(i), joinField(field, name), errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			interpolateValue(v.Index(i), fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			// Map values are not addressable, they are interpolated in a copy
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			interpolateValue(value, joinField(field, fmt.Sprint(key)), errs)
+			v.SetMapIndex(key, value)
+		}
+
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandReferences(v.String(), field, errs))
+		}
+	}
+}
+
+// expandReferences replaces the references within a value with their
+// values.
+func expandReferences(value string, field string, errs *ValidationErrors) string {
+	return referenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+
+		resolved, err := resolveReference(reference)
+		if err != nil {
+			err.Field = field
+			*errs = append(*errs, err)
+		}
+		return resolved
+	})
+}
+
+func joinField(field string, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
+
+// resolveReference returns the value of a reference, Ex: ${HOME}.
+func resolveReference(reference string) (string, *ValidationError) {
+	if !strings.HasSuffix(reference, "}") {
+		return "", referenceError("InvalidReference", reference, "Invalid reference '%s' provided. References must be closed with a '}'. Ex: ${ENV_VAR}", reference)
+	}
+	expr := reference[2 : len(reference)-1]
+
+	if strings.HasPrefix(expr, "file:") {
+		path := helpers.FilePathHelper.GetFullPath(strings.TrimPrefix(expr, "file:"))
+		value, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", referenceError("UnresolvedReference", reference, "Unable to resolve reference '%s'. Error while reading file '%s' :: %s", reference, path, err)
+		}
+		return strings.TrimRight(string(value), "\r\n"), nil
+	}
+
+	name, fallback, hasFallback := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		name, fallback, hasFallback = expr[:i], expr[i+2:], true
+	}
+	if !envVarRegex.MatchString(name) {
+		return "", referenceError("InvalidReference", reference, "Invalid reference '%s' provided. Please provide ${ENV_VAR}, ${ENV_VAR:-default} or ${file:/path/to/file}", reference)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if hasFallback && value == "" {
+		return fallback, nil
+	}
+	if !ok {
+		return "", referenceError("UnresolvedReference", reference, "Unable to resolve reference '%s'. Environment variable '%s' is not set. Please set it or provide a default value. Ex: ${%s:-default}", reference, name, name)
+	}
+	return value, nil
+}
+
+func referenceError(code string, reference string, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Code:    code,
+		Value:   reference,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
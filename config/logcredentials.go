@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/saidmithilesh/hodor/helpers"
+)
+
+// Kafka acknowledgement levels
+const (
+	KafkaAcksNone   = "NONE"
+	KafkaAcksLeader = "LEADER"
+	KafkaAcksAll    = "ALL"
+)
+
+// Kafka SASL mechanisms
+const (
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLScramSHA256 = "SCRAM-SHA-256"
+	KafkaSASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// LogCredentials encapsulates the contents of the log credentials file,
+// the connection details of the remote log outputs. They are kept apart
+// from the gateway config since they usually contain secrets.
+type LogCredentials struct {
+	Kafka KafkaLogConfig `yaml:"kafka"`
+	ELK   ELKLogConfig   `yaml:"elk"`
+}
+
+// LogBatchConfig encapsulates the configuration shared by the remote log
+// outputs. Log lines are buffered in memory, up to BufferSize of them, and
+// shipped in batches of up to BatchSize lines at least once every
+// FlushInterval. Log lines are dropped rather than blocking requests when
+// the buffer is full. Timeout bounds every call made to the remote service.
+type LogBatchConfig struct {
+	BufferSize          int    `yaml:"buffer_size"`
+	BatchSize           int    `yaml:"batch_size"`
+	FlushIntervalString string `yaml:"flush_interval"`
+	TimeoutString       string `yaml:"timeout"`
+
+	// FlushInterval and Timeout strings converted into time.Duration
+	FlushIntervalDuration time.Duration
+	TimeoutDuration       time.Duration
+}
+
+// KafkaLogConfig encapsulates the configuration for shipping logs to a
+// Kafka topic.
+type KafkaLogConfig struct {
+	Brokers  []string        `yaml:"brokers"`
+	Topic    string          `yaml:"topic"`
+	ClientID string          `yaml:"client_id"`
+	Acks     string          `yaml:"acks"`
+	SASL     KafkaSASLConfig `yaml:"sasl"`
+	TLS      TLSClientConfig `yaml:"tls"`
+
+	LogBatchConfig `yaml:",inline"`
+}
+
+// ELKLogConfig encapsulates the configuration for shipping logs to
+// Elasticsearch through its bulk API. Index may contain a date in Go's
+// time layout within braces, which is replaced with the (UTC) date each
+// log line has been written on. Ex: 'hodor-{2006.01.02}'. Batches that
+// cannot be indexed are retried up to MaxRetries times, with exponential
+// backoff. Either a username and password or an API key can be provided
+// to authenticate.
+type ELKLogConfig struct {
+	URLs       []string        `yaml:"urls"`
+	Index      string          `yaml:"index"`
+	Username   string          `yaml:"username"`
+	Password   string          `yaml:"password"`
+	APIKey     string          `yaml:"api_key"`
+	MaxRetries int             `yaml:"max_retries"`
+	TLS        TLSClientConfig `yaml:"tls"`
+
+	LogBatchConfig `yaml:",inline"`
+}
+
+// KafkaSASLConfig encapsulates the credentials to authenticate with the
+// Kafka brokers. SASL is disabled if no mechanism is provided.
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// TLSClientConfig encapsulates the configuration for connecting to a
+// remote log output over TLS. The certificate and key are only required if
+// the service authenticates clients by their certificates.
+type TLSClientConfig struct {
+	Enabled            bool   `yaml:"enable"`
+	CACertFilePath     string `yaml:"ca_cert"`
+	CertFilePath       string `yaml:"cert"`
+	KeyFilePath        string `yaml:"key"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func (lc *LogCredentials) load(path string, c *Config) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.fail("InvalidLogCredentials", "gateway.log_credentials", path, "Error while reading log credentials file '%s' :: %s", path, err)
+		return
+	}
+
+	if err := yaml.Unmarshal(content, lc); err != nil {
+		c.fail("InvalidLogCredentials", "gateway.log_credentials", path, "Error while parsing log credentials file '%s' :: %s", path, err)
+		return
+	}
+	c.Errors = append(c.Errors, interpolate(lc, "log_credentials")...)
+	c.addSource("log_credentials", path, content)
+}
+
+func (kc *KafkaLogConfig) validate(c *Config) {
+	if len(kc.Brokers) == 0 {
+		c.fail("MissingKafkaBrokers", "log_credentials.kafka.brokers", "", "Log output KAFKA requires at least one broker. Please provide the addresses of the brokers in kafka.brokers of the log credentials file. Ex: kafka-1:9092")
+	}
+	for i, broker := range kc.Brokers {
+		if !portRegex.MatchString(broker) {
+			c.fail("InvalidKafkaBroker", fmt.Sprintf("log_credentials.kafka.brokers[%d]", i), broker, "Invalid value '%s' provided for kafka broker. Please provide a valid address of format <host>:<port>. Ex: kafka-1:9092", broker)
+		}
+	}
+
+	if kc.Topic == "" {
+		c.fail("MissingKafkaTopic", "log_credentials.kafka.topic", kc.Topic, "Log output KAFKA requires a topic. Please provide it in kafka.topic of the log credentials file.")
+	}
+
+	switch strings.ToUpper(kc.Acks) {
+	case "", KafkaAcksNone, KafkaAcksLeader, KafkaAcksAll:
+	default:
+		c.fail("InvalidKafkaAcks", "log_credentials.kafka.acks", kc.Acks, "Invalid value '%s' provided for kafka acks. Allowed values are NONE, LEADER and ALL (case insensitive).", kc.Acks)
+	}
+
+	switch strings.ToUpper(kc.SASL.Mechanism) {
+	case "":
+	case KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512:
+		if kc.SASL.Username == "" {
+			c.fail("MissingKafkaSASLUsername", "log_credentials.kafka.sasl.username", kc.SASL.Username, "Kafka SASL authentication requires a username. Please provide it in kafka.sasl.username of the log credentials file.")
+		}
+	default:
+		c.fail("InvalidKafkaSASLMechanism", "log_credentials.kafka.sasl.mechanism", kc.SASL.Mechanism, "Invalid value '%s' provided for kafka SASL mechanism. Allowed values are PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512 (case insensitive).", kc.SASL.Mechanism)
+	}
+
+	kc.TLS.validate("log_credentials.kafka", c)
+	kc.LogBatchConfig.validate("log_credentials.kafka", c)
+}
+
+func (ec *ELKLogConfig) validate(c *Config) {
+	if len(ec.URLs) == 0 {
+		c.fail("MissingELKURLs", "log_credentials.elk.urls", "", "Log output ELK requires at least one elasticsearch URL. Please provide them in elk.urls of the log credentials file. Ex: https://elasticsearch:9200")
+	}
+	for i, u := range ec.URLs {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			c.fail("InvalidELKURL", fmt.Sprintf("log_credentials.elk.urls[%d]", i), u, "Invalid value '%s' provided for elasticsearch URL. Please provide a valid http or https URL. Ex: https://elasticsearch:9200", u)
+		}
+	}
+
+	if strings.Count(ec.Index, "{") != strings.Count(ec.Index, "}") || strings.Count(ec.Index, "{") > 1 || strings.Index(ec.Index, "}") < strings.Index(ec.Index, "{") {
+		c.fail("InvalidELKIndex", "log_credentials.elk.index", ec.Index, "Invalid value '%s' provided for elasticsearch index. The index may contain a single date layout within braces. Ex: hodor-{2006.01.02}", ec.Index)
+	}
+
+	if ec.APIKey != "" && ec.Username != "" {
+		c.fail("InvalidELKCredentials", "log_credentials.elk.api_key", ec.APIKey, "Both a username and an API key have been provided for elasticsearch. Please provide only one of them.")
+	}
+
+	if ec.MaxRetries < 0 {
+		c.fail("InvalidELKMaxRetries", "log_credentials.elk.max_retries", ec.MaxRetries, "Invalid value '%d' provided for elasticsearch max retries. Please provide a positive number.", ec.MaxRetries)
+	}
+
+	ec.TLS.validate("log_credentials.elk", c)
+	ec.LogBatchConfig.validate("log_credentials.elk", c)
+}
+
+func (bc *LogBatchConfig) validate(field string, c *Config) {
+	output := strings.TrimPrefix(field, "log_credentials.")
+	for _, size := range []keyedInt{{"buffer_size", bc.BufferSize}, {"batch_size", bc.BatchSize}} {
+		if size.value < 0 {
+			c.fail("InvalidLogBufferSize", field+"."+size.key, size.value, "Invalid value '%d' provided for %s %s. Please provide a positive number.", size.value, output, size.key)
+		}
+	}
+
+	for _, duration := range []keyedString{{"flush_interval", bc.FlushIntervalString}, {"timeout", bc.TimeoutString}} {
+		if duration.value != "" && (!durationRegex.MatchString(duration.value) || stringToDuration(duration.value) <= 0) {
+			c.fail("InvalidLogDuration", field+"."+duration.key, duration.value, "Invalid value '%s' provided for %s %s. Please provide a valid string of format <length><time_unit> with a length of at least 1. Ex: 1S or 10S.", duration.value, output, duration.key)
+		}
+	}
+}
+
+func (tc *TLSClientConfig) validate(field string, c *Config) {
+	if !tc.Enabled {
+		return
+	}
+
+	output := strings.TrimPrefix(field, "log_credentials.")
+	if (tc.CertFilePath == "") != (tc.KeyFilePath == "") {
+		c.fail("InvalidLogTLS", field+".tls.cert", tc.CertFilePath, "TLS client authentication requires both a certificate and a key. Please provide both %s.tls.cert and %s.tls.key in the log credentials file.", output, output)
+	}
+
+	paths := []struct {
+		key  string
+		path *string
+	}{
+		{"ca_cert", &tc.CACertFilePath},
+		{"cert", &tc.CertFilePath},
+		{"key", &tc.KeyFilePath},
+	}
+	for _, p := range paths {
+		if *p.path == "" {
+			continue
+		}
+
+		*p.path = helpers.FilePathHelper.GetFullPath(*p.path)
+		if !helpers.FilePathHelper.IsValidPath(*p.path) {
+			c.fail("InvalidLogTLSPath", field+".tls."+p.key, *p.path, "Invalid filepath '%s' provided for %s TLS certificate or key file", *p.path, output)
+		}
+	}
+}
+
+func (kc *KafkaLogConfig) optimise(gc *GatewayConfig) {
+	if kc.ClientID == "" {
+		kc.ClientID = "hodor-" + strings.ToLower(strings.ReplaceAll(gc.Name, " ", "-"))
+	}
+
+	kc.Acks = strings.ToUpper(kc.Acks)
+	if kc.Acks == "" {
+		kc.Acks = KafkaAcksLeader
+	}
+	kc.SASL.Mechanism = strings.ToUpper(kc.SASL.Mechanism)
+	kc.LogBatchConfig.optimise()
+}
+
+func (ec *ELKLogConfig) optimise() {
+	if ec.Index == "" {
+		ec.Index = "hodor-{2006.01.02}"
+	}
+	if ec.MaxRetries == 0 {
+		ec.MaxRetries = 3
+	}
+	for i := range ec.URLs {
+		ec.URLs[i] = strings.TrimSuffix(ec.URLs[i], "/")
+	}
+	ec.LogBatchConfig.optimise()
+}
+
+func (bc *LogBatchConfig) optimise() {
+	if bc.BufferSize == 0 {
+		bc.BufferSize = 10000
+	}
+	if bc.BatchSize == 0 {
+		bc.BatchSize = 500
+	}
+
+	bc.FlushIntervalDuration = time.Second
+	if bc.FlushIntervalString != "" {
+		bc.FlushIntervalDuration = stringToDuration(strings.ToUpper(bc.FlushIntervalString))
+	}
+
+	bc.TimeoutDuration = 10 * time.Second
+	if bc.TimeoutString != "" {
+		bc.TimeoutDuration = stringToDuration(strings.ToUpper(bc.TimeoutString))
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLogBatchConfigDurations(t *testing.T) {
+	tests := []struct {
+		flushInterval string
+		timeout       string
+		fields        []string
+	}{
+		{"", "", nil},
+		{"1S", "10s", nil},
+		{"0S", "10S", []string{"log_credentials.kafka.flush_interval"}},
+		{"1S", "0m", []string{"log_credentials.kafka.timeout"}},
+		{"1", "S", []string{"log_credentials.kafka.flush_interval", "log_credentials.kafka.timeout"}},
+	}
+
+	for _, test := range tests {
+		c := &Config{}
+		bc := &LogBatchConfig{FlushIntervalString: test.flushInterval, TimeoutString: test.timeout}
+		bc.validate("log_credentials.kafka", c)
+
+		var fields []string
+		for _, err := range c.Errors {
+			if err.Code != "InvalidLogDuration" {
+				t.Errorf("expected InvalidLogDuration, got %s", err.Code)
+			}
+			fields = append(fields, err.Field)
+		}
+		if fmt.Sprint(fields) != fmt.Sprint(test.fields) {
+			t.Errorf("flush interval '%s' and timeout '%s' :: expected errors for %v, got %v", test.flushInterval, test.timeout, test.fields, fields)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// source is a file the config has been read from. Errors on fields under
+// prefix are located in its content, under root. File is empty for the
+// config file itself, its path being only known to Load.
+type source struct {
+	prefix  string
+	root    string
+	file    string
+	content []byte
+}
+
+// yamlEntry is a mapping key or a sequence item of a YAML document.
+// Column is that of the key or of the item's dash, Value that of the value
+// following the key on the same line, if any. Both are 1-indexed.
+type yamlEntry struct {
+	line   int
+	column int
+	value  int
+	key    string
+	item   bool
+}
+
+var (
+	yamlKeyRegex       = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s"'#\-][^:#]*?|-[^\s:#][^:#]*?)\s*:(\s|$)`)
+	yamlErrorLineRegex = regexp.MustCompile(`^line (\d+): `)
+	fieldSegmentRegex  = regexp.MustCompile(`[^.\[\]]+|\[\d+\]`)
+)
+
+// addSource registers a file the config has been read from.
+func (conf *Config) addSource(prefix string, file string, content []byte) {
+	conf.sources = append(conf.sources, source{prefix: prefix, file: file, content: content})
+}
+
+// sourceOf returns the source the field has been read from, nil if it is
+// not known.
+func (conf *Config) sourceOf(field string) *source {
+	var src *source
+	for i := range conf.sources {
+		s := &conf.sources[i]
+		if !hasFieldPrefix(field, s.prefix) {
+			continue
+		}
+		if src == nil || len(s.prefix) > len(src.prefix) {
+			src = s
+		}
+	}
+	return src
+}
+
+// locate finds the file, line and column of the fields the validation
+// errors are about. Errors found while reading a file are located already.
+func (conf *Config) locate() {
+	for _, err := range conf.Errors {
+		if err.Line > 0 || err.File != "" {
+			continue
+		}
+
+		src := conf.sourceOf(err.Field)
+		if src == nil {
+			continue
+		}
+		err.File = src.file
+		err.Line, err.Column = locateField(src.content, src.root+strings.TrimPrefix(err.Field, src.prefix))
+	}
+}
+
+// hasFieldPrefix checks whether the field is the prefix or one of its
+// descendants. Ex: gateway.endpoints[1].path is under gateway.endpoints[1]
+// but not gateway.endpoints[10].path.
+func hasFieldPrefix(field string, prefix string) bool {
+	if prefix == "" || field == prefix {
+		return true
+	}
+	return strings.HasPrefix(field, prefix) && strings.IndexByte(".[", field[len(prefix)]) >= 0
+}
+
+// locateField finds the line and column of a field of a YAML document,
+// given its path. Ex: gateway.endpoints[2].method. The position of the
+// value is returned when it follows the key on the same line. When the
+// field is missing from the document, the position of its closest
+// ancestor present is returned. Only block style YAML, the one config
+// files are written in, is looked into: fields within flow style
+// collections, aliases and block scalars are located at the collection,
+// alias or scalar as a whole.
+func locateField(content []byte, field string) (int, int) {
+	entries := yamlEntries(content)
+	line, column := 0, 0
+
+	lo, hi := 0, len(entries)
+	for _, segment := range fieldSegmentRegex.FindAllString(field, -1) {
+		if lo >= hi {
+			break
+		}
+		childColumn := entries[lo].column
+
+		found := -1
+		if strings.HasPrefix(segment, "[") {
+			index, _ := strconv.Atoi(strings.Trim(segment, "[]"))
+			for i := lo; i < hi; i++ {
+				if entries[i].column == childColumn && entries[i].item {
+					if index == 0 {
+						found = i
+						break
+					}
+					index--
+				}
+			}
+		} else {
+			for i := lo; i < hi; i++ {
+				if entries[i].column == childColumn && !entries[i].item && entries[i].key == segment {
+					found = i
+					break
+				}
+			}
+		}
+		if found < 0 {
+			break
+		}
+
+		entry := entries[found]
+		line, column = entry.line, entry.column
+		if entry.value > 0 {
+			column = entry.value
+		}
+
+		// Sequences are commonly written at the indentation of their key
+		lo, hi = found+1, found+1
+		for hi < len(entries) {
+			next := entries[hi]
+			if next.column > entry.column || (!entry.item && entry.value == 0 && next.item && next.column == entry.column) {
+				hi++
+				continue
+			}
+			break
+		}
+	}
+	return line, column
+}
+
+// yamlEntries lists the mapping keys and sequence items of a YAML
+// document, in order. Block scalars are skipped.
+func yamlEntries(content []byte) []yamlEntry {
+	entries := []yamlEntry{}
+	blockIndent := -1
+
+	for i, text := range strings.Split(string(content), "\n") {
+		text = strings.TrimRight(text, " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		indent := len(text) - len(trimmed)
+
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		column := indent + 1
+		for trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			entries = append(entries, yamlEntry{line: i + 1, column: column, item: true})
+			rest := strings.TrimLeft(strings.TrimPrefix(trimmed, "-"), " ")
+			column += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+
+		match := yamlKeyRegex.FindStringSubmatch(trimmed)
+		if match == nil {
+			if n := len(entries); n > 0 && entries[n-1].line == i+1 && trimmed != "" {
+				entries[n-1].value = column
+			}
+			continue
+		}
+
+		entry := yamlEntry{line: i + 1, column: column, key: strings.Trim(match[1], `"'`)}
+		rest := strings.TrimLeft(trimmed[len(match[0]):], " ")
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			entry.value = column + len(trimmed) - len(rest)
+			if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+				blockIndent = column - 1
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// yamlErrorLine extracts the line number from the message of an error met
+// while unmarshalling a YAML document, Ex: 'line 4: cannot unmarshal ...'
+func yamlErrorLine(message string) int {
+	match := yamlErrorLineRegex.FindStringSubmatch(message)
+	if match == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
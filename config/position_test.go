@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testDocument exercises the YAML styles config files are written in.
+// Lines are numbered in the comments at their end.
+const testDocument = `# comment                              # 1
+gateway:                                 # 2
+  name: "test"                           # 3
+  "quoted key": 'value'                  # 4
+  description: |                         # 5
+    name: not a key                      # 6
+    - not an item                        # 7
+  endpoints:                             # 8
+  - id: 1                                # 9
+    path: /users                         # 10
+    backend: "${BACKEND}"                # 11
+  -   id: 2                              # 12
+      methods:                           # 13
+        - GET                            # 14
+        - - nested                       # 15
+  ports: [80, 443]                       # 16
+  tls:                                   # 17
+    enable: true                         # 18
+`
+
+func TestYAMLEntries(t *testing.T) {
+	want := []yamlEntry{
+		{line: 2, column: 1, key: "gateway"},
+		{line: 3, column: 3, value: 9, key: "name"},
+		{line: 4, column: 3, value: 17, key: "quoted key"},
+		{line: 5, column: 3, value: 16, key: "description"},
+		{line: 8, column: 3, key: "endpoints"},
+		{line: 9, column: 3, item: true},
+		{line: 9, column: 5, value: 9, key: "id"},
+		{line: 10, column: 5, value: 11, key: "path"},
+		{line: 11, column: 5, value: 14, key: "backend"},
+		{line: 12, column: 3, item: true},
+		{line: 12, column: 7, value: 11, key: "id"},
+		{line: 13, column: 7, key: "methods"},
+		{line: 14, column: 9, value: 11, item: true},
+		{line: 15, column: 9, item: true},
+		{line: 15, column: 11, value: 13, item: true},
+		{line: 16, column: 3, value: 10, key: "ports"},
+		{line: 17, column: 3, key: "tls"},
+		{line: 18, column: 5, value: 13, key: "enable"},
+	}
+
+	got := yamlEntries([]byte(testDocument))
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d :: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d :: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestLocateField(t *testing.T) {
+	tests := []struct {
+		field  string
+		line   int
+		column int
+	}{
+		{"gateway", 2, 1},
+		{"gateway.name", 3, 9},
+		{"gateway.quoted key", 4, 17},
+		{"gateway.description", 5, 16},
+		{"gateway.endpoints", 8, 3},
+		{"gateway.endpoints[0]", 9, 3},
+		{"gateway.endpoints[0].id", 9, 9},
+		{"gateway.endpoints[0].backend", 11, 14},
+		{"gateway.endpoints[1].id", 12, 11},
+		{"gateway.endpoints[1].methods[0]", 14, 11},
+		{"gateway.endpoints[1].methods[1][0]", 15, 13},
+		{"gateway.ports", 16, 10},
+		{"gateway.tls.enable", 18, 13},
+		// Fields missing from the document are located at their closest
+		// ancestor present
+		{"gateway.endpoints[0].method", 9, 3},
+		{"gateway.endpoints[2].path", 8, 3},
+		{"gateway.tls.cert_file", 17, 3},
+		// Keys of other mappings are not mistaken for the field
+		{"gateway.id", 2, 1},
+		{"gateway.endpoints[1].path", 12, 3},
+		// Flow style values are not looked into
+		{"gateway.ports[1]", 16, 10},
+		{"log_credentials", 0, 0},
+	}
+
+	for _, test := range tests {
+		line, column := locateField([]byte(testDocument), test.field)
+		if line != test.line || column != test.column {
+			t.Errorf("%s :: expected %d:%d, got %d:%d", test.field, test.line, test.column, line, column)
+		}
+	}
+}
+
+func TestLocateValidationErrors(t *testing.T) {
+	content := fmt.Sprintf(testConfig, "10S") + `  - id: 2
+    name: "payments"
+    method: GET
+    path: /payments
+    backend: "${HODOR_TEST_UNSET_BACKEND}"
+`
+	_, err := Parse([]byte(content))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	// The unresolved reference leaves the backend empty, which is
+	// reported as well, at the same position
+	want := map[string]string{
+		"InvalidBackend":      "gateway.endpoints[1].backend 20:14",
+		"UnresolvedReference": "gateway.endpoints[1].backend 20:14",
+	}
+	for _, e := range errs {
+		if w, ok := want[e.Code]; ok {
+			if got := fmt.Sprintf("%s %d:%d", e.Field, e.Line, e.Column); got != w {
+				t.Errorf("%s :: expected %s, got %s", e.Code, w, got)
+			}
+			delete(want, e.Code)
+		}
+	}
+	for code := range want {
+		t.Errorf("expected a %s error, got %v", code, errs)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RouteTable resolves requests to the endpoints of a config the way the
+// router of the gateway does. The router finds whether a request matches
+// a route and the values of its parameters, the endpoint is then looked up
+// among those of the method by the path of its route.
+type RouteTable struct {
+	router    *httprouter.Router
+	endpoints map[string][]*EndpointConfig
+}
+
+func newRouteTable() *RouteTable {
+	return &RouteTable{router: httprouter.New(), endpoints: make(map[string][]*EndpointConfig)}
+}
+
+// NewRouteTable creates the route table of a validated config.
+func NewRouteTable(gc *GatewayConfig) *RouteTable {
+	rt := newRouteTable()
+	for i := range gc.Endpoints {
+		rt.add(&gc.Endpoints[i])
+	}
+	return rt
+}
+
+// Match returns the endpoint a request for the method and path is routed
+// to, along with the values of the path parameters. Redirect is set if no
+// endpoint matches but one would with a trailing slash added or removed.
+func (rt *RouteTable) Match(method string, path string) (endpoint *EndpointConfig, params httprouter.Params, redirect bool) {
+	method = strings.ToUpper(method)
+	handle, params, redirect := rt.router.Lookup(method, path)
+	if handle == nil {
+		return nil, nil, redirect
+	}
+
+	for _, e := range rt.endpoints[method] {
+		if expandPath(e.Path, params) == path {
+			return e, params, false
+		}
+	}
+	return nil, nil, false
+}
+
+// expandPath replaces the parameters of the path of a route with their
+// values. Ex: /users/:id with id=42 is /users/42. Only the route a request
+// has been matched to expands into the path of the request, as the router
+// refuses routes which would match the same requests.
+func expandPath(route string, params httprouter.Params) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if j := strings.IndexAny(segment, ":*"); j >= 0 {
+			value := params.ByName(segment[j+1:])
+			if segment[j] == '*' {
+				// Catch-all values start with the slash preceding them
+				value = strings.TrimPrefix(value, "/")
+			}
+			segments[i] = segment[:j] + value
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Allowed lists the methods of the endpoints matching the path.
+func (rt *RouteTable) Allowed(path string) []string {
+	var allowed []string
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions} {
+		if handle, _, _ := rt.router.Lookup(method, path); handle != nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// add routes the endpoint's method and path to it. The error returned
+// is the reason the router refuses the route, if it does.
+func (rt *RouteTable) add(e *EndpointConfig) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	method := strings.ToUpper(e.Method)
+	rt.router.Handle(method, e.Path, func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+	rt.endpoints[method] = append(rt.endpoints[method], e)
+	return nil
+}
+
+// validateRoutes makes sure that the router accepts the routes of all
+// the endpoints. The router refuses paths that are invalid, and paths
+// which conflict with the path of another endpoint of the same method,
+// such as '/users/:id' and '/users/:name' or '/users/all'.
+func (gc *GatewayConfig) validateRoutes(c *Config) {
+	rt := newRouteTable()
+	for i := range gc.Endpoints {
+		e := &gc.Endpoints[i]
+		if !methodsRegex.MatchString(e.Method) || e.Path == "" {
+			continue
+		}
+
+		field := fmt.Sprintf("gateway.endpoints[%d].path", i)
+		err := rt.add(e)
+		if err == nil {
+			continue
+		}
+
+		if alone := newRouteTable().add(e); alone != nil {
+			c.fail("InvalidPath", field, e.Path, "Invalid value '%s' provided for endpoint %s. Please provide a valid path :: %s", e.Path, e.Name, alone)
+			continue
+		}
+		if j := gc.conflictingEndpoint(i); j >= 0 {
+			other := &gc.Endpoints[j]
+			code := "ConflictingPath"
+			if other.Path == e.Path {
+				code = "DuplicateRoute"
+			}
+			c.fail(code, field, e.Path, "Path '%s' of endpoint %s conflicts with path '%s' of endpoint %s for method %s :: %s", e.Path, e.Name, other.Path, c.describeEndpoint(j, i), strings.ToUpper(e.Method), err)
+			continue
+		}
+		c.fail("ConflictingPath", field, e.Path, "Path '%s' of endpoint %s conflicts with the paths of other endpoints for method %s :: %s", e.Path, e.Name, strings.ToUpper(e.Method), err)
+	}
+}
+
+// conflictingEndpoint finds the index of the endpoint, among those
+// preceding the one at index i, which the router refuses to route
+// alongside it. Returns -1 if there is none.
+func (gc *GatewayConfig) conflictingEndpoint(i int) int {
+	e := &gc.Endpoints[i]
+	for j := 0; j < i; j++ {
+		other := &gc.Endpoints[j]
+		if !strings.EqualFold(other.Method, e.Method) {
+			continue
+		}
+
+		rt := newRouteTable()
+		if rt.add(other) == nil && rt.add(e) != nil {
+			return j
+		}
+	}
+	return -1
+}
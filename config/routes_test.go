@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRouteTableMatch(t *testing.T) {
+	gc := &GatewayConfig{Endpoints: []EndpointConfig{
+		{ID: 1, Method: "GET", Path: "/users"},
+		{ID: 2, Method: "GET", Path: "/users/:id"},
+		{ID: 3, Method: "GET", Path: "/users/:id/orders"},
+		{ID: 4, Method: "POST", Path: "/users/:id"},
+		{ID: 5, Method: "GET", Path: "/files/*path"},
+		{ID: 6, Method: "GET", Path: "/v1/user_:name"},
+	}}
+	rt := NewRouteTable(gc)
+
+	tests := []struct {
+		method   string
+		path     string
+		id       int
+		params   string
+		redirect bool
+	}{
+		{"GET", "/users", 1, "[]", false},
+		{"get", "/users/42", 2, "[{id 42}]", false},
+		{"GET", "/users/42/orders", 3, "[{id 42}]", false},
+		{"POST", "/users/42", 4, "[{id 42}]", false},
+		{"GET", "/files/a/b.txt", 5, "[{path /a/b.txt}]", false},
+		{"GET", "/files/", 5, "[{path /}]", false},
+		{"GET", "/v1/user_jane", 6, "[{name jane}]", false},
+		{"GET", "/users/", 0, "", true},
+		{"DELETE", "/users/42", 0, "", false},
+		{"GET", "/orders", 0, "", false},
+	}
+
+	for _, test := range tests {
+		e, params, redirect := rt.Match(test.method, test.path)
+		id := 0
+		if e != nil {
+			id = int(e.ID)
+		}
+		if id != test.id || redirect != test.redirect {
+			t.Errorf("%s %s :: expected endpoint %d and redirect %t, got %d and %t", test.method, test.path, test.id, test.redirect, id, redirect)
+			continue
+		}
+		if e != nil && fmt.Sprint(params) != test.params {
+			t.Errorf("%s %s :: expected params %s, got %v", test.method, test.path, test.params, params)
+		}
+	}
+
+	if allowed := fmt.Sprint(rt.Allowed("/users/42")); allowed != "[GET POST]" {
+		t.Errorf("expected GET and POST to be allowed, got %s", allowed)
+	}
+}
@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/logging"
+)
+
+// responseRecorder records the status and the size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(p)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streamed responses be flushed through the recorder.
+func (rr *responseRecorder) Flush() {
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.bytes += int64(n)
+	return n, err
+}
+
+// accessLog wraps the handle to write the access log line of every
+// request once it has been responded to.
+func (e *Endpoint) accessLog(next httprouter.Handle) httprouter.Handle {
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if logging.AccessLog == nil {
+			next(res, req, params)
+			return
+		}
+
+		info := requestInfoFromContext(req.Context())
+		start := time.Now()
+		clientIP := e.clientIP(req)
+		uri := req.RequestURI
+		recorder := &responseRecorder{ResponseWriter: res}
+		body := &countingBody{ReadCloser: req.Body}
+		if req.Body != nil {
+			req.Body = body
+		}
+
+		next(recorder, req, params)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		bytesIn := req.ContentLength
+		if bytesIn < 0 {
+			bytesIn = body.bytes
+		}
+
+		logging.AccessLog.Log(&logging.AccessEntry{
+			Time:            start,
+			RequestID:       info.ID,
+			EndpointID:      e.Config.ID,
+			EndpointName:    e.Config.Name,
+			Method:          req.Method,
+			URI:             uri,
+			Proto:           req.Proto,
+			Status:          recorder.status,
+			Latency:         time.Since(start),
+			UpstreamLatency: info.UpstreamLatency,
+			BytesIn:         bytesIn,
+			BytesOut:        recorder.bytes,
+			ClientIP:        clientIP,
+			UserAgent:       req.UserAgent(),
+			Referer:         req.Referer(),
+			Consumer:        info.Consumer,
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/logging"
+)
+
+// testGatewayConfig configures a single CORS enabled endpoint and an
+// access log written to the file provided as a format argument.
+const testGatewayConfig = `gateway:
+  id: 1
+  name: "test"
+  port: ":8080"
+  log_level: "ERROR"
+  access_log:
+    enable: true
+    format: "TEMPLATE"
+    template: "{{.RequestID}} {{.EndpointID}} {{.Method}} {{.URI}} {{.Status}}"
+    output: "FILE"
+    file:
+      path: %q
+  cors:
+    enable: true
+    allowed_domains:
+    - "*"
+  endpoints:
+  - id: 1
+    name: "users"
+    method: GET
+    path: /users
+    backend: "http://127.0.0.1:1"
+`
+
+// newTestGateway builds a gateway from testGatewayConfig and returns it
+// along with a function reading the lines of its access log.
+func newTestGateway(t *testing.T) (*Gateway, func() []string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "hodor-gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "access.log")
+
+	conf, err := config.Parse([]byte(fmt.Sprintf(testGatewayConfig, path)))
+	if err != nil {
+		t.Fatalf("invalid test config :: %s", err)
+	}
+	logging.BuildLogger(conf)
+	logging.Logger = zap.NewNop()
+	t.Cleanup(func() {
+		logging.AccessLog = nil
+		os.RemoveAll(dir)
+	})
+
+	g := &Gateway{}
+	if err := g.build(conf); err != nil {
+		t.Fatalf("failed to build the gateway :: %s", err)
+	}
+
+	return g, func() []string {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	}
+}
+
+func TestAccessLogUnmatchedRequests(t *testing.T) {
+	g, accessLog := newTestGateway(t)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/users", nil)
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	requests := []struct {
+		req    *http.Request
+		status int
+	}{
+		{httptest.NewRequest(http.MethodGet, "/missing", nil), http.StatusNotFound},
+		{httptest.NewRequest(http.MethodPost, "/users?page=2", nil), http.StatusMethodNotAllowed},
+		{preflight, http.StatusNoContent},
+	}
+	for _, request := range requests {
+		request.req.Header.Set("X-Request-ID", "test-id")
+		res := httptest.NewRecorder()
+		g.ServeHTTP(res, request.req)
+		if res.Code != request.status {
+			t.Errorf("%s %s :: expected status %d, got %d", request.req.Method, request.req.RequestURI, request.status, res.Code)
+		}
+	}
+
+	want := []string{
+		"test-id 0 GET /missing 404",
+		"test-id 0 POST /users?page=2 405",
+		"test-id 0 OPTIONS /users 204",
+	}
+	if got := accessLog(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected access log lines %q, got %q", want, got)
+	}
+}
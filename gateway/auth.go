@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/auth"
+	"github.com/saidmithilesh/hodor/logging"
+	"go.uber.org/zap"
+)
+
+// authenticate wraps the handle with the endpoint's authenticator.
+// Requests without valid credentials are rejected with a 401 status.
+// The consumer an accepted request has been authenticated as is attached
+// to the request context and passed on to the backend in the consumer
+// header, which clients therefore cannot set themselves.
+func (e *Endpoint) authenticate(next httprouter.Handle) httprouter.Handle {
+	if e.Authenticator == nil {
+		return next
+	}
+
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		req.Header.Del(e.Auth.ConsumerHeader)
+
+		consumer, err := e.Authenticator.Authenticate(req)
+		if err != nil {
+			if errors.Is(err, auth.ErrBodyTooLarge) {
+				logging.Logger.Info(
+					"Request body too large",
+					zap.Uint("epid", e.Config.ID),
+					zap.String("epname", e.Config.Name),
+					zap.String("epmethod", e.Config.Method),
+					zap.String("reqid", requestID(req.Context())),
+					zap.Int64("maxBodySize", e.Gateway.MaxBodySizeBytes),
+				)
+				res.WriteHeader(http.StatusRequestEntityTooLarge)
+				fmt.Fprintf(res, "Request entity too large")
+				return
+			}
+			if !errors.Is(err, auth.ErrUnauthenticated) {
+				logging.Logger.Error(
+					"Error while authenticating request",
+					zap.Uint("epid", e.Config.ID),
+					zap.String("epname", e.Config.Name),
+					zap.String("epmethod", e.Config.Method),
+					zap.String("reqid", requestID(req.Context())),
+					zap.Error(err),
+				)
+				res.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintf(res, "Internal server error")
+				return
+			}
+
+			logging.Logger.Info(
+				"Request authentication failed",
+				zap.Uint("epid", e.Config.ID),
+				zap.String("epname", e.Config.Name),
+				zap.String("epmethod", e.Config.Method),
+				zap.String("reqid", requestID(req.Context())),
+				zap.String("authType", e.Auth.Type),
+				zap.Error(err),
+			)
+			if challenger, ok := e.Authenticator.(auth.Challenger); ok {
+				res.Header().Set("WWW-Authenticate", challenger.Challenge())
+			}
+			res.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(res, "Unauthorized")
+			return
+		}
+
+		req.Header.Set(e.Auth.ConsumerHeader, consumer)
+		req = req.WithContext(WithConsumer(req.Context(), consumer))
+		next(res, req, params)
+	}
+}
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey int
+
+const (
+	consumerContextKey contextKey = iota
+	requestInfoContextKey
+)
+
+// requestInfo collects what is learnt about a request while it passes
+// through the chain of handles, to be reported once it is responded to.
+type requestInfo struct {
+	ID              string
+	Consumer        string
+	UpstreamLatency time.Duration
+}
+
+// WithConsumer returns a copy of the context carrying the name of the
+// consumer the request has been authenticated as. The gateway's own
+// authentication attaches the consumer to every request it accepts, before
+// rate limits keyed by consumer count the requests of each consumer
+// separately. Native middleware implementing a custom authentication can
+// do the same, but they run after the rate limits: the consumer they
+// attach is logged and traced while the rate limits have counted the
+// request by IP.
+func WithConsumer(ctx context.Context, consumer string) context.Context {
+	if info := requestInfoFromContext(ctx); info != nil {
+		info.Consumer = consumer
+	}
+	return context.WithValue(ctx, consumerContextKey, consumer)
+}
+
+// ConsumerFromContext returns the name of the consumer attached to the
+// context, if any.
+func ConsumerFromContext(ctx context.Context) (string, bool) {
+	consumer, ok := ctx.Value(consumerContextKey).(string)
+	return consumer, ok && consumer != ""
+}
+
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey, info)
+}
+
+// requestInfoFromContext returns the info of the request the context
+// belongs to, or nil outside of the chain of handles.
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoContextKey).(*requestInfo)
+	return info
+}
+
+// RequestIDFromContext returns the id of the request the context belongs
+// to, as received in or generated for the request id header, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id := requestID(ctx)
+	return id, id != ""
+}
+
+// requestID returns the id of the request the context belongs to, or an
+// empty string outside of the chain of handles.
+func requestID(ctx context.Context) string {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
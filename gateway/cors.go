@@ -0,0 +1,229 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/logging"
+	"go.uber.org/zap"
+)
+
+// safelistedHeaders are the request headers browsers send cross origin
+// without listing them in preflights, except for Content-Type values
+// other than those of HTML forms.
+var safelistedHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+
+// cors wraps the handle with the endpoint's CORS policy. Requests from
+// origins that are not allowed are rejected with a 403 status, all other
+// cross origin requests are answered with the Access-Control-* headers.
+// Preflight requests reaching an endpoint configured for the OPTIONS
+// method are answered the same way as automatic preflights.
+func (e *Endpoint) cors(next httprouter.Handle) httprouter.Handle {
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if e.Preflight != nil && isPreflight(req) {
+			e.Preflight.ServeHTTP(res, req)
+			return
+		}
+
+		origin := req.Header.Get("Origin")
+		if e.CORS == nil || origin == "" {
+			next(res, req, params)
+			return
+		}
+
+		res.Header().Add("Vary", "Origin")
+		if !originAllowed(e.CORS, origin) || !methodAllowed(e.CORS, req.Method) {
+			e.rejectCORS(res, req, origin, "Origin not allowed")
+			return
+		}
+
+		setAllowOrigin(res, e.CORS, origin)
+		if len(e.CORS.ExposedHeaders) > 0 {
+			res.Header().Set("Access-Control-Expose-Headers", strings.Join(e.CORS.ExposedHeaders, ", "))
+		}
+
+		next(res, req, params)
+	}
+}
+
+// preflight answers a preflight request on behalf of the endpoint whose
+// method is requested by it.
+func (e *Endpoint) preflight(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	origin := req.Header.Get("Origin")
+	if e.CORS == nil || origin == "" {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	res.Header().Add("Vary", "Origin")
+	res.Header().Add("Vary", "Access-Control-Request-Method")
+	res.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if !originAllowed(e.CORS, origin) {
+		e.rejectCORS(res, req, origin, "Origin not allowed")
+		return
+	}
+
+	method := req.Header.Get("Access-Control-Request-Method")
+	if !methodAllowed(e.CORS, method) {
+		e.rejectCORS(res, req, origin, "Method not allowed")
+		return
+	}
+
+	requested := splitHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+	for _, header := range requested {
+		if !headerAllowed(e.CORS, header) {
+			e.rejectCORS(res, req, origin, "Header not allowed")
+			return
+		}
+	}
+
+	setAllowOrigin(res, e.CORS, origin)
+	res.Header().Set("Access-Control-Allow-Methods", method)
+	if len(requested) > 0 {
+		res.Header().Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if e.CORS.MaxAgeDuration > 0 {
+		maxAge := int64(e.CORS.MaxAgeDuration.Seconds())
+		res.Header().Set("Access-Control-Max-Age", strconv.FormatInt(maxAge, 10))
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Endpoint) rejectCORS(res http.ResponseWriter, req *http.Request, origin string, reason string) {
+	logging.Logger.Info(
+		"CORS request rejected",
+		zap.Uint("epid", e.Config.ID),
+		zap.String("epname", e.Config.Name),
+		zap.String("epmethod", e.Config.Method),
+		zap.String("reqid", requestID(req.Context())),
+		zap.String("origin", origin),
+		zap.String("reason", reason),
+	)
+	res.WriteHeader(http.StatusForbidden)
+	fmt.Fprint(res, reason)
+}
+
+// preflight answers preflight requests for all registered paths. The
+// preflight router mirrors the main router, except that its handles
+// answer preflights on behalf of the endpoints.
+func (g *Gateway) preflight(res http.ResponseWriter, req *http.Request) {
+	method := req.Header.Get("Access-Control-Request-Method")
+	if method == "" {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	handle, params, _ := g.preflightRouter.Lookup(method, req.URL.Path)
+	if handle == nil {
+		res.Header().Add("Vary", "Origin")
+		res.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(res, "Method not allowed")
+		return
+	}
+	handle(res, req, params)
+}
+
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func setAllowOrigin(res http.ResponseWriter, cors *config.CORSConfig, origin string) {
+	// Browsers refuse a wildcard origin on credentialed requests,
+	// so the origin is echoed back whenever credentials are allowed
+	if !cors.AllowCredentials && len(cors.AllowedDomains) == 1 && cors.AllowedDomains[0] == "*" {
+		res.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	res.Header().Set("Access-Control-Allow-Origin", origin)
+	if cors.AllowCredentials {
+		res.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// originAllowed matches the origin against the allowed domains. Domains
+// including a scheme only match origins using that scheme, domains
+// starting with '*.' match any subdomain of the rest of the domain, on
+// any port unless the domain includes one.
+func originAllowed(cors *config.CORSConfig, origin string) bool {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, domain := range cors.AllowedDomains {
+		host := domain
+		if i := strings.Index(domain, "://"); i >= 0 {
+			if domain[:i] != u.Scheme {
+				continue
+			}
+			host = domain[i+3:]
+		}
+
+		switch {
+		case host == "*":
+			return true
+		case strings.HasPrefix(host, "*."):
+			suffix, port := host[1:], ""
+			if i := strings.LastIndex(suffix, ":"); i >= 0 {
+				suffix, port = suffix[:i], suffix[i+1:]
+			}
+			if strings.HasSuffix(u.Hostname(), suffix) && (port == "" || port == u.Port()) {
+				return true
+			}
+		case host == u.Host:
+			return true
+		}
+	}
+	return false
+}
+
+// methodAllowed checks the method against the allowed methods. If no
+// methods are configured, all methods are allowed since the router only
+// lets the endpoint's own method through anyway.
+func methodAllowed(cors *config.CORSConfig, method string) bool {
+	if len(cors.AllowedMethods) == 0 {
+		return true
+	}
+
+	for _, allowed := range cors.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// headerAllowed checks the header against the allowed headers. If no
+// headers are configured, only the CORS-safelisted headers are allowed.
+func headerAllowed(cors *config.CORSConfig, header string) bool {
+	allowedHeaders := cors.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = safelistedHeaders
+	}
+
+	for _, allowed := range allowedHeaders {
+		if strings.EqualFold(allowed, header) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHeaderList(list string) []string {
+	var headers []string
+	for _, header := range strings.Split(list, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		domain  string
+		origin  string
+		allowed bool
+	}{
+		{"*", "https://example.com", true},
+		{"example.com", "https://example.com", true},
+		{"example.com", "https://example.com:8443", false},
+		{"localhost:1337", "http://localhost:1337", true},
+		{"localhost:1337", "http://localhost:1338", false},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "http://example.com", false},
+		{"*.example.com", "https://api.example.com", true},
+		{"*.example.com", "https://api.example.com:8443", true},
+		{"*.example.com", "https://a.b.example.com", true},
+		{"*.example.com", "https://example.com", false},
+		{"*.example.com", "https://evilexample.com", false},
+		{"*.example.com", "https://example.com.evil.com", false},
+		{"*.example.com:8443", "https://api.example.com:8443", true},
+		{"*.example.com:8443", "https://api.example.com", false},
+		{"https://*.example.com", "https://api.example.com:8443", true},
+		{"https://*.example.com", "http://api.example.com", false},
+		{"example.com", "null", false},
+	}
+
+	for _, test := range tests {
+		cors := &config.CORSConfig{AllowedDomains: []string{test.domain}}
+		if got := originAllowed(cors, test.origin); got != test.allowed {
+			t.Errorf("origin '%s' for domain '%s' :: expected %t, got %t", test.origin, test.domain, test.allowed, got)
+		}
+	}
+}
+
+func TestHeaderAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		header  string
+		want    bool
+	}{
+		{nil, "Content-Type", true},
+		{nil, "accept-language", true},
+		{nil, "Authorization", false},
+		{[]string{"Authorization"}, "authorization", true},
+		{[]string{"Authorization"}, "Content-Type", false},
+	}
+
+	for _, test := range tests {
+		cors := &config.CORSConfig{AllowedHeaders: test.allowed}
+		if got := headerAllowed(cors, test.header); got != test.want {
+			t.Errorf("header '%s' for allowed headers %v :: expected %t, got %t", test.header, test.allowed, test.want, got)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	e := &Endpoint{
+		Config: &config.EndpointConfig{ID: 1, Name: "users", Method: "POST"},
+		CORS:   &config.CORSConfig{AllowedDomains: []string{"*.example.com"}},
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/users", nil)
+	req.Header.Set("Origin", "https://app.example.com:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	res := httptest.NewRecorder()
+	e.preflight(res, req, nil)
+
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d :: %s", res.Code, res.Body)
+	}
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com:3000" {
+		t.Errorf("expected the origin to be allowed, got '%s'", got)
+	}
+	if got := res.Header().Get("Access-Control-Allow-Headers"); got != "content-type" {
+		t.Errorf("expected the requested headers to be allowed, got '%s'", got)
+	}
+}
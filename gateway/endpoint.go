@@ -6,13 +6,16 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/auth"
 	"github.com/saidmithilesh/hodor/config"
 	"github.com/saidmithilesh/hodor/logging"
+	"github.com/saidmithilesh/hodor/metrics"
+	"github.com/saidmithilesh/hodor/ratelimit"
+	"github.com/saidmithilesh/hodor/tracing"
 	"go.uber.org/zap"
-
-	uuid "github.com/satori/go.uuid"
 )
 
 // Endpoint data type
@@ -20,17 +23,57 @@ import (
 type Endpoint struct {
 	Backend *url.URL
 	Config  *config.EndpointConfig
+
+	// Gateway level config the endpoint falls back to
+	// wherever it does not override it
+	Gateway *config.GatewayConfig
+
+	// Limiters enforce the rate limits applicable to the endpoint.
+	// It is empty if neither the endpoint nor the gateway is rate limited.
+	Limiters []*ratelimit.Limiter
+
+	// CORS is the CORS config applicable to the endpoint. It is nil
+	// if CORS is enabled on neither the endpoint nor the gateway.
+	CORS *config.CORSConfig
+
+	// Preflight answers preflight requests reaching an endpoint
+	// configured for the OPTIONS method.
+	Preflight http.Handler
+
+	// Auth is the auth config applicable to the endpoint and
+	// Authenticator the authenticator built from it. Both are
+	// nil if the endpoint does not require authentication.
+	Auth          *config.AuthConfig
+	Authenticator auth.Authenticator
+
+	// Middleware the request is passed through in series before it
+	// is proxied to the backend, starting with those of the gateway
+	Middleware []*Middleware
+
+	// Metrics of the gateway, nil if metrics are disabled
+	Metrics *metrics.Metrics
+
+	// Tracer of the gateway, nil if tracing is disabled
+	Tracer *tracing.Tracer
+}
+
+// handle assembles the chain of handles a request passes through
+// before being proxied to the backend.
+func (e *Endpoint) handle() httprouter.Handle {
+	return e.identify(e.accessLog(e.instrument(e.trace(e.cors(e.authenticate(e.rateLimit(e.middleware(e.proxyFunc))))))))
 }
 
 func (e *Endpoint) proxyFunc(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	requestID := uuid.NewV4().String()
+	info := requestInfoFromContext(req.Context())
+	consumer, _ := ConsumerFromContext(req.Context())
 
 	logging.Logger.Info(
 		"New request",
 		zap.Uint("epid", e.Config.ID),
 		zap.String("epname", e.Config.Name),
 		zap.String("epmethod", e.Config.Method),
-		zap.String("reqid", requestID),
+		zap.String("reqid", info.ID),
+		zap.String("consumer", consumer),
 	)
 
 	req.Host = e.Backend.Host
@@ -40,14 +83,34 @@ func (e *Endpoint) proxyFunc(res http.ResponseWriter, req *http.Request, _ httpr
 
 	remoteAddr, _, _ := net.SplitHostPort(req.RemoteAddr)
 	req.Header.Set("X-Forwarded-For", remoteAddr)
-
-	response, err := http.DefaultClient.Do(req)
+	// Middleware must not be able to tamper with the request id
+	req.Header.Set(e.Gateway.RequestIDHeader, info.ID)
+
+	ctx, span := e.Tracer.Start(req.Context(), "backend "+e.Backend.Host, tracing.SpanKindClient)
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.full", req.URL.String())
+	span.SetAttribute("server.address", e.Backend.Hostname())
+	span.Inject(req.Header)
+
+	start := time.Now()
+	response, err := http.DefaultClient.Do(req.WithContext(ctx))
+	info.UpstreamLatency = time.Since(start)
+	endClientSpan(span, response, err)
 	if err != nil {
+		if e.Metrics != nil {
+			reason := "error"
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				reason = "timeout"
+			}
+			e.Metrics.UpstreamErrors.With(e.metricLabels(reason)...).Inc()
+		}
+
 		logging.Logger.Info(
 			"Request forwarding failed",
 			zap.Uint("epid", e.Config.ID),
 			zap.String("epname", e.Config.Name),
 			zap.String("epmethod", e.Config.Method),
+			zap.String("reqid", info.ID),
 			zap.Error(err),
 		)
 		res.WriteHeader(http.StatusInternalServerError)
@@ -55,6 +118,8 @@ func (e *Endpoint) proxyFunc(res http.ResponseWriter, req *http.Request, _ httpr
 		return
 	}
 
+	defer response.Body.Close()
+
 	// Copy all the headers received from the backend
 	for key, values := range response.Header {
 		for _, value := range values {
@@ -63,37 +128,40 @@ func (e *Endpoint) proxyFunc(res http.ResponseWriter, req *http.Request, _ httpr
 	}
 	res.WriteHeader(response.StatusCode)
 	io.Copy(res, response.Body)
+	info.UpstreamLatency = time.Since(start)
 }
 
 // Build the functionality for the endpoint
 func (e *Endpoint) Build(r *httprouter.Router) *Endpoint {
+	handle := e.handle()
+
 	switch e.Config.Method {
 	case http.MethodGet:
-		r.GET(e.Config.Path, e.proxyFunc)
+		r.GET(e.Config.Path, handle)
 		return e
 
 	case http.MethodPost:
-		r.POST(e.Config.Path, e.proxyFunc)
+		r.POST(e.Config.Path, handle)
 		return e
 
 	case http.MethodPut:
-		r.PUT(e.Config.Path, e.proxyFunc)
+		r.PUT(e.Config.Path, handle)
 		return e
 
 	case http.MethodDelete:
-		r.DELETE(e.Config.Path, e.proxyFunc)
+		r.DELETE(e.Config.Path, handle)
 		return e
 
 	case http.MethodPatch:
-		r.PATCH(e.Config.Path, e.proxyFunc)
+		r.PATCH(e.Config.Path, handle)
 		return e
 
 	case http.MethodHead:
-		r.HEAD(e.Config.Path, e.proxyFunc)
+		r.HEAD(e.Config.Path, handle)
 		return e
 
 	case http.MethodOptions:
-		r.OPTIONS(e.Config.Path, e.proxyFunc)
+		r.OPTIONS(e.Config.Path, handle)
 		return e
 
 	default:
@@ -123,5 +191,6 @@ func NewEndpoint(conf *config.EndpointConfig) Endpoint {
 	}
 
 	e.Backend = remoteURL
+
 	return e
 }
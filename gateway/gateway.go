@@ -1,10 +1,19 @@
 package gateway
 
 import (
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 
+	"github.com/saidmithilesh/hodor/auth"
 	"github.com/saidmithilesh/hodor/config"
 	"github.com/saidmithilesh/hodor/logging"
+	"github.com/saidmithilesh/hodor/metrics"
+	"github.com/saidmithilesh/hodor/ratelimit"
+	"github.com/saidmithilesh/hodor/tracing"
 
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
@@ -19,43 +28,358 @@ type Gateway struct {
 	Router    *httprouter.Router
 	Config    *config.Config
 	Endpoints []*Endpoint
+
+	// Limiters are shared by all endpoints that do not override the
+	// gateway wide rate limits. It is empty if rate limiting is disabled.
+	Limiters []*ratelimit.Limiter
+
+	// Store holds the counters of all rate limiters of the gateway
+	Store ratelimit.Store
+
+	// Middleware every request passes through before
+	// the middleware of the endpoint it is made to
+	Middleware []*Middleware
+
+	// Consumers allowed to authenticate with the gateway. It is
+	// nil if no consumers file has been configured.
+	Consumers *auth.Consumers
+
+	// Authenticator shared by all endpoints that do not override
+	// the gateway wide authentication. It is nil if disabled.
+	Authenticator auth.Authenticator
+
+	// Metrics collected by all endpoints, nil if disabled
+	Metrics *metrics.Metrics
+
+	// Tracer starting the spans of all endpoints, nil if disabled
+	Tracer *tracing.Tracer
+
+	// preflightRouter mirrors the router with handles answering
+	// CORS preflight requests on behalf of each endpoint
+	preflightRouter *httprouter.Router
+
+	// server proxying the requests and admin server
+	server *http.Server
+	admin  *http.Server
+
+	// draining is set, atomically, once the gateway shuts down
+	draining int32
+
+	// current holds the gateway built from the config in use once
+	// the config has been reloaded
+	current atomic.Value
 }
 
 // Build method associates the gateway's config, sets up the router,
 // and configures individual end points.
 func (g *Gateway) Build(conf *config.Config) *Gateway {
+	if conf.Gateway.EnableMetrics {
+		g.Metrics = metrics.New(&conf.Gateway)
+	}
+	if conf.Gateway.Tracing.Enabled {
+		g.Tracer = tracing.New(&conf.Gateway)
+	}
+	g.buildStore(&conf.Gateway.RateLimit.Store)
+
+	if err := g.build(conf); err != nil {
+		logging.Logger.Fatal(err.message, err.fields...)
+	}
+	return g
+}
+
+// buildError is an error met while building the gateway from its config,
+// along with the fields identifying the part of the config it is about.
+type buildError struct {
+	message string
+	fields  []zap.Field
+}
+
+func (be *buildError) Error() string {
+	return be.message
+}
+
+// build sets up the router and the endpoints for the config. The rate
+// limiter store, the metrics and the tracer must have been created before.
+func (g *Gateway) build(conf *config.Config) (buildErr *buildError) {
 	g.Config = conf
 	g.Router = httprouter.New()
+	g.Router.NotFound = g.unmatched(http.HandlerFunc(http.NotFound))
+	g.Router.MethodNotAllowed = g.unmatched(http.HandlerFunc(methodNotAllowed))
+	g.Router.GlobalOPTIONS = g.unmatched(http.HandlerFunc(g.preflight))
+	g.preflightRouter = httprouter.New()
 
-	for _, epc := range g.Config.Gateway.Endpoints {
-		endpoint := NewEndpoint(&epc)
+	g.Limiters = g.newLimiters("gateway", g.Config.Gateway.EnabledRateLimits())
+	var err *buildError
+	if g.Middleware, err = newMiddlewareChain(g.Config.Gateway.Middleware, zap.String("level", config.CFLevelGateway)); err != nil {
+		return err
+	}
+	if err = g.buildAuth(); err != nil {
+		return err
+	}
+
+	// httprouter panics on paths conflicting with one another. These are
+	// caught by validation, the panic is only recovered from as a safeguard.
+	var epc *config.EndpointConfig
+	defer func() {
+		if r := recover(); r != nil {
+			buildErr = &buildError{
+				message: "Error while configuring route for endpoint",
+				fields: []zap.Field{
+					zap.Uint("epid", epc.ID),
+					zap.String("epname", epc.Name),
+					zap.String("epmethod", epc.Method),
+					zap.String("eppath", epc.Path),
+					zap.Any("error", r),
+				},
+			}
+		}
+	}()
+
+	for i := range g.Config.Gateway.Endpoints {
+		epc = &g.Config.Gateway.Endpoints[i]
+		endpoint := NewEndpoint(epc)
+		endpoint.Gateway = &g.Config.Gateway
+		endpoint.Limiters = g.limitersFor(endpoint.Config)
+		endpoint.CORS = g.Config.Gateway.CORSFor(endpoint.Config)
+		endpoint.Preflight = http.HandlerFunc(g.preflight)
+		endpoint.Auth = g.Config.Gateway.AuthFor(endpoint.Config)
+		if endpoint.Authenticator, err = g.authenticatorFor(endpoint.Config, endpoint.Auth); err != nil {
+			return err
+		}
+		var chain []*Middleware
+		if chain, err = newMiddlewareChain(endpoint.Config.Middleware, zap.Uint("epid", endpoint.Config.ID)); err != nil {
+			return err
+		}
+		endpoint.Middleware = append(append([]*Middleware(nil), g.Middleware...), chain...)
+		endpoint.Metrics = g.Metrics
+		endpoint.Tracer = g.Tracer
 		endpoint.Build(g.Router)
+		g.preflightRouter.Handle(endpoint.Config.Method, endpoint.Config.Path, endpoint.preflight)
 		g.Endpoints = append(g.Endpoints, &endpoint)
 	}
 
-	return g
+	return nil
 }
 
-// Start method starts the http server using the router setup from
-// the Build method.
-func (g *Gateway) Start() {
-	var err error
+// unmatched wraps a handler answering the requests the router matches to
+// no endpoint, so that they are identified and logged like the requests
+// made to endpoints. They are logged under endpoint id 0, without a name.
+func (g *Gateway) unmatched(handler http.Handler) http.Handler {
+	e := &Endpoint{Config: &config.EndpointConfig{}, Gateway: &g.Config.Gateway}
+	handle := e.identify(e.accessLog(func(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		handler.ServeHTTP(res, req)
+	}))
 
-	server := http.Server{}
-	server.Handler = g.Router
-	server.Addr = g.Config.Gateway.Port
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		handle(res, req, nil)
+	})
+}
 
-	// If gateway is configured with TLS enabled
-	if g.Config.Gateway.EnableTLS {
-		err = server.ListenAndServeTLS(g.Config.Gateway.TLSCertFilePath, g.Config.Gateway.TLSKeyFilePath)
-	} else {
-		err = server.ListenAndServe()
-	}
+// methodNotAllowed answers requests to paths routed for other methods
+// only, the router has set the Allow header already.
+func methodNotAllowed(res http.ResponseWriter, req *http.Request) {
+	http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
 
+// buildStore creates the store shared by all rate limiters of the
+// gateway. An unreachable redis server is not fatal since the limiters
+// let requests through while the store is unavailable.
+func (g *Gateway) buildStore(storeConf *config.RateLimitStoreConfig) {
+	store, err := ratelimit.NewStore(storeConf)
 	if err != nil {
 		logging.Logger.Fatal(
-			"Error while starting http server",
+			"Error while creating rate limiter store",
+			zap.String("store", storeConf.Type),
 			zap.Error(err),
 		)
 	}
+
+	if redisStore, ok := store.(*ratelimit.RedisStore); ok {
+		if err := redisStore.Ping(); err != nil {
+			logging.Logger.Warn(
+				"Rate limiter store is unreachable",
+				zap.String("store", storeConf.Type),
+				zap.String("address", storeConf.Address),
+				zap.Error(err),
+			)
+		}
+	}
+
+	g.Store = store
+}
+
+// buildAuth loads the consumers allowed to authenticate with the gateway
+// and creates the gateway wide authenticator.
+func (g *Gateway) buildAuth() *buildError {
+	if g.Config.Gateway.ConsumersFilePath != "" {
+		consumers, err := auth.LoadConsumers(g.Config.Gateway.ConsumersFilePath)
+		if err != nil {
+			return &buildError{
+				message: "Error while loading consumers file",
+				fields: []zap.Field{
+					zap.String("path", g.Config.Gateway.ConsumersFilePath),
+					zap.Error(err),
+				},
+			}
+		}
+		g.Consumers = consumers
+	}
+
+	if gwAuth := &g.Config.Gateway.Auth; gwAuth.Enabled && gwAuth.Type != config.AuthTypeNone {
+		var err *buildError
+		if g.Authenticator, err = g.newAuthenticator(gwAuth, zap.String("level", config.CFLevelGateway)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authenticatorFor returns the authenticator applicable to an endpoint.
+// Endpoints overriding the gateway wide authentication get their own.
+func (g *Gateway) authenticatorFor(epc *config.EndpointConfig, authConf *config.AuthConfig) (auth.Authenticator, *buildError) {
+	switch authConf {
+	case nil:
+		return nil, nil
+	case &g.Config.Gateway.Auth:
+		return g.Authenticator, nil
+	default:
+		return g.newAuthenticator(authConf, zap.Uint("epid", epc.ID))
+	}
+}
+
+func (g *Gateway) newAuthenticator(authConf *config.AuthConfig, field zap.Field) (auth.Authenticator, *buildError) {
+	authenticator, err := auth.New(authConf, g.Consumers)
+	if err != nil {
+		return nil, &buildError{
+			message: "Error while configuring authentication",
+			fields: []zap.Field{
+				field,
+				zap.String("authType", authConf.Type),
+				zap.Error(err),
+			},
+		}
+	}
+	return authenticator, nil
+}
+
+// newMiddlewareChain creates the middleware of a chain in the order they
+// are configured in. The field identifies the chain in the error returned
+// when a middleware cannot be created.
+func newMiddlewareChain(confs []config.MiddlewareConfig, field zap.Field) ([]*Middleware, *buildError) {
+	var chain []*Middleware
+	for i := range confs {
+		mw, err := NewMiddleware(&confs[i])
+		if err != nil {
+			return nil, &buildError{
+				message: "Error while configuring middleware",
+				fields: []zap.Field{
+					field,
+					zap.String("name", confs[i].Name),
+					zap.String("url", confs[i].URL),
+					zap.Error(err),
+				},
+			}
+		}
+		chain = append(chain, mw)
+	}
+	return chain, nil
+}
+
+// limitersFor returns the rate limiters applicable to an endpoint. The
+// endpoint level rate limits take precedence over the gateway wide ones.
+func (g *Gateway) limitersFor(epc *config.EndpointConfig) []*ratelimit.Limiter {
+	if rateLimits := epc.EnabledRateLimits(); len(rateLimits) > 0 {
+		return g.newLimiters(fmt.Sprintf("endpoint:%d", epc.ID), rateLimits)
+	}
+	return g.Limiters
+}
+
+// newLimiters creates a limiter for each rate limit. Each one is named
+// after its position so that stacked limiters never share counters.
+func (g *Gateway) newLimiters(name string, rateLimits []*config.RateLimiterConfig) []*ratelimit.Limiter {
+	var limiters []*ratelimit.Limiter
+	for i, rl := range rateLimits {
+		limiters = append(limiters, ratelimit.NewLimiter(fmt.Sprintf("%s:%d", name, i), rl, g.Store))
+	}
+	return limiters
+}
+
+// startAdmin starts the admin server in the background, if an admin
+// port has been configured.
+func (g *Gateway) startAdmin() {
+	if g.Config.Gateway.AdminPort == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", g.live)
+	mux.HandleFunc("/readyz", g.ready)
+	if g.Metrics != nil {
+		mux.Handle("/metrics", g.Metrics.Registry)
+	}
+	g.admin = &http.Server{Addr: g.Config.Gateway.AdminPort, Handler: mux}
+
+	go func() {
+		if err := g.admin.ListenAndServe(); err != http.ErrServerClosed {
+			logging.Logger.Fatal(
+				"Error while starting admin server",
+				zap.String("port", g.Config.Gateway.AdminPort),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+// Start method starts the http server using the router setup from
+// the Build method. The config is reloaded on a SIGHUP or when the
+// config file changes. Start returns once the gateway has been shut
+// down by a SIGTERM or SIGINT and the requests in flight have completed.
+func (g *Gateway) Start() {
+	g.startAdmin()
+
+	g.server = &http.Server{}
+	g.server.Handler = g
+	g.server.Addr = g.Config.Gateway.Port
+
+	errs := make(chan error, 1)
+	go func() {
+		// If gateway is configured with TLS enabled
+		if g.Config.Gateway.EnableTLS {
+			errs <- g.server.ListenAndServeTLS(g.Config.Gateway.TLSCertFilePath, g.Config.Gateway.TLSKeyFilePath)
+		} else {
+			errs <- g.server.ListenAndServe()
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	changes := g.watchConfig()
+
+	for {
+		select {
+		case err := <-errs:
+			logging.Logger.Fatal(
+				"Error while starting http server",
+				zap.Error(err),
+			)
+
+		case <-changes:
+			g.Reload("file change")
+
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				g.Reload("SIGHUP")
+				continue
+			}
+
+			logging.Logger.Info(
+				"Shutting down",
+				zap.Stringer("signal", sig),
+				zap.Duration("timeout", g.active().Config.Gateway.ShutdownTimeoutDuration),
+			)
+			g.Shutdown()
+			return
+		}
+	}
 }
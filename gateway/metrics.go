@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/metrics"
+)
+
+// instrument wraps the handle to count the requests made to the endpoint,
+// the requests in flight and the time taken to respond to them.
+func (e *Endpoint) instrument(next httprouter.Handle) httprouter.Handle {
+	if e.Metrics == nil {
+		return next
+	}
+
+	inFlight := e.Metrics.InFlight.With(e.metricLabels()...)
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: res}
+		next(recorder, req, params)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		labels := e.metricLabels(metrics.StatusClass(recorder.status))
+		e.Metrics.Requests.With(labels...).Inc()
+		e.Metrics.RequestDuration.With(labels...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// metricLabels returns the values of the labels identifying the endpoint
+// in metrics, followed by the given ones.
+func (e *Endpoint) metricLabels(values ...string) []string {
+	return append([]string{strconv.FormatUint(uint64(e.Config.ID), 10), e.Config.Name, e.Config.Method}, values...)
+}
@@ -0,0 +1,327 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/logging"
+	"github.com/saidmithilesh/hodor/tracing"
+	"go.uber.org/zap"
+)
+
+// Middleware is a single step of the chain a request passes through
+// before it is proxied to the backend. It is either a native middleware
+// registered under a name, or an HTTP service the request is replayed to.
+// A non 2xx response from an HTTP middleware is sent to the client as it
+// is and the request goes no further. A 2xx response can modify the
+// request headers seen by the rest of the chain and the backend, as
+// described by config.MiddlewareConfig.
+type Middleware struct {
+	Native MiddlewareFunc
+	URL    *url.URL
+	Config *config.MiddlewareConfig
+
+	client *http.Client
+}
+
+// NewMiddleware creates an instance of type Middleware from the (already
+// optimised) middleware config. Native middleware must have been
+// registered before.
+func NewMiddleware(conf *config.MiddlewareConfig) (*Middleware, error) {
+	if conf.Name != "" {
+		native, ok := LookupMiddleware(conf.Name)
+		if !ok {
+			return nil, fmt.Errorf("no middleware registered under the name '%s'", conf.Name)
+		}
+		return &Middleware{Native: native, Config: conf}, nil
+	}
+
+	mwURL, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Middleware{
+		URL:    mwURL,
+		Config: conf,
+		client: &http.Client{
+			Timeout: conf.TimeoutDuration,
+			// Redirects are responses like any other and
+			// are sent to the client instead of followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// call replays the request to the middleware with the original method,
+// headers and body. The original URI and host are passed along in the
+// X-Forwarded-Uri and X-Forwarded-Host headers.
+func (mw *Middleware) call(req *http.Request, body []byte) (*http.Response, error) {
+	mwReq, err := http.NewRequest(req.Method, mw.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	mwReq = mwReq.WithContext(req.Context())
+
+	for key, values := range req.Header {
+		mwReq.Header[key] = append([]string(nil), values...)
+	}
+	tracing.SpanFromContext(req.Context()).Inject(mwReq.Header)
+
+	remoteAddr, _, _ := net.SplitHostPort(req.RemoteAddr)
+	mwReq.Header.Set("X-Forwarded-For", remoteAddr)
+	mwReq.Header.Set("X-Forwarded-Host", req.Host)
+	mwReq.Header.Set("X-Forwarded-Uri", req.URL.RequestURI())
+
+	return mw.client.Do(mwReq)
+}
+
+// mutateHeaders applies the request header changes the middleware asked
+// for in its response headers. Removals are applied first, then sets and
+// finally additions, so that the outcome never depends on header order.
+// Sets and additions of headers that are not mutable are ignored.
+func (mw *Middleware) mutateHeaders(mwHeaders http.Header, reqHeaders http.Header) {
+	for _, header := range mw.Config.ForwardHeaders {
+		if values, ok := mwHeaders[header]; ok {
+			reqHeaders[header] = append([]string(nil), values...)
+		}
+	}
+
+	if mw.Config.HeaderPrefix == "" {
+		return
+	}
+
+	removePrefix := mw.Config.HeaderPrefix + "Remove"
+	setPrefix := mw.Config.HeaderPrefix + "Set-"
+	addPrefix := mw.Config.HeaderPrefix + "Add-"
+
+	for _, value := range mwHeaders[removePrefix] {
+		for _, header := range splitHeaderList(value) {
+			reqHeaders.Del(header)
+		}
+	}
+
+	for key, values := range mwHeaders {
+		if header := strings.TrimPrefix(key, setPrefix); header != key && mw.isMutable(header) {
+			reqHeaders[header] = append([]string(nil), values...)
+		}
+	}
+
+	for key, values := range mwHeaders {
+		if header := strings.TrimPrefix(key, addPrefix); header != key && mw.isMutable(header) {
+			reqHeaders[header] = append(reqHeaders[header], values...)
+		}
+	}
+}
+
+// isMutable reports whether the middleware may set or add to the header.
+func (mw *Middleware) isMutable(header string) bool {
+	for _, mutable := range mw.Config.MutableHeaders {
+		if header == mutable {
+			return true
+		}
+	}
+	return false
+}
+
+// String identifies the middleware in logs
+func (mw *Middleware) String() string {
+	if mw.Native != nil {
+		return mw.Config.Name
+	}
+	return mw.Config.URL
+}
+
+// wrap turns the middleware into a handler passing the request on to next.
+func (mw *Middleware) wrap(e *Endpoint, next http.Handler) http.Handler {
+	if mw.Native != nil {
+		return mw.Native(next)
+	}
+
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := bufferBody(req, e.Gateway.MaxBodySizeBytes)
+		if err == errBodyTooLarge {
+			logging.Logger.Info(
+				"Request body too large",
+				zap.Uint("epid", e.Config.ID),
+				zap.String("epname", e.Config.Name),
+				zap.String("epmethod", e.Config.Method),
+				zap.String("reqid", requestID(req.Context())),
+				zap.Int64("maxBodySize", e.Gateway.MaxBodySizeBytes),
+			)
+			res.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprintf(res, "Request entity too large")
+			return
+		}
+		if err != nil {
+			logging.Logger.Info(
+				"Error while reading request body",
+				zap.Uint("epid", e.Config.ID),
+				zap.String("epname", e.Config.Name),
+				zap.String("epmethod", e.Config.Method),
+				zap.String("reqid", requestID(req.Context())),
+				zap.Error(err),
+			)
+			res.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(res, "Bad request")
+			return
+		}
+
+		if e.callMiddleware(mw, res, req, body) {
+			next.ServeHTTP(res, req)
+		}
+	})
+}
+
+// bufferedBody is a request body read into memory, so that it can be
+// replayed to each middleware and finally to the backend.
+type bufferedBody struct {
+	*bytes.Reader
+	content []byte
+}
+
+func (b *bufferedBody) Close() error {
+	return nil
+}
+
+// errBodyTooLarge is returned by bufferBody for bodies beyond its limit.
+var errBodyTooLarge = errors.New("request body too large")
+
+// bufferBody reads the request body into memory, unless that has already
+// been done by a previous middleware, and rewinds it for the next reader.
+// Bodies of more than limit bytes are not read any further and rejected
+// with errBodyTooLarge.
+func bufferBody(req *http.Request, limit int64) ([]byte, error) {
+	var content []byte
+	if buffered, ok := req.Body.(*bufferedBody); ok {
+		content = buffered.content
+	} else {
+		if req.ContentLength > limit {
+			return nil, errBodyTooLarge
+		}
+
+		var err error
+		content, err = ioutil.ReadAll(io.LimitReader(req.Body, limit+1))
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(content)) > limit {
+			return nil, errBodyTooLarge
+		}
+	}
+
+	req.Body = &bufferedBody{Reader: bytes.NewReader(content), content: content}
+	req.ContentLength = int64(len(content))
+	return content, nil
+}
+
+// middleware wraps the handle with the middleware chain of the endpoint,
+// preceded by that of the gateway. The route's parameters are attached
+// to the request context, where native middleware can retrieve them
+// with httprouter.ParamsFromContext.
+func (e *Endpoint) middleware(next httprouter.Handle) httprouter.Handle {
+	if len(e.Middleware) == 0 {
+		return next
+	}
+
+	var chain http.Handler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		next(res, req, httprouter.ParamsFromContext(req.Context()))
+	})
+	for i := len(e.Middleware) - 1; i >= 0; i-- {
+		chain = e.Middleware[i].wrap(e, chain)
+	}
+
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		// Only middleware may set the headers they forward or mutate
+		for _, mw := range e.Middleware {
+			for _, header := range mw.Config.ForwardHeaders {
+				req.Header.Del(header)
+			}
+			for _, header := range mw.Config.MutableHeaders {
+				req.Header.Del(header)
+			}
+		}
+
+		ctx := context.WithValue(req.Context(), httprouter.ParamsKey, params)
+		chain.ServeHTTP(res, req.WithContext(ctx))
+	}
+}
+
+// callMiddleware calls a single middleware and reports whether the
+// request may proceed. If not, the response has already been written.
+func (e *Endpoint) callMiddleware(mw *Middleware, res http.ResponseWriter, req *http.Request, body []byte) bool {
+	ctx, span := e.Tracer.Start(req.Context(), "middleware "+mw.String(), tracing.SpanKindClient)
+	span.SetAttribute("url.full", mw.Config.URL)
+
+	start := time.Now()
+	response, err := mw.call(req.WithContext(ctx), body)
+	endClientSpan(span, response, err)
+	if e.Metrics != nil {
+		e.Metrics.MiddlewareDuration.With(e.metricLabels(mw.String())...).Observe(time.Since(start).Seconds())
+	}
+	if err != nil {
+		logging.Logger.Error(
+			"Middleware call failed",
+			zap.Uint("epid", e.Config.ID),
+			zap.String("epname", e.Config.Name),
+			zap.String("epmethod", e.Config.Method),
+			zap.String("reqid", requestID(req.Context())),
+			zap.Stringer("middleware", mw),
+			zap.String("onFailure", mw.Config.OnFailure),
+			zap.Error(err),
+		)
+
+		if mw.Config.OnFailure == config.MWFailOpen {
+			return true
+		}
+
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			res.WriteHeader(http.StatusGatewayTimeout)
+			fmt.Fprintf(res, "Gateway timeout")
+			return false
+		}
+		res.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(res, "Bad gateway")
+		return false
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		io.Copy(ioutil.Discard, response.Body)
+		mw.mutateHeaders(response.Header, req.Header)
+		return true
+	}
+
+	logging.Logger.Info(
+		"Request rejected by middleware",
+		zap.Uint("epid", e.Config.ID),
+		zap.String("epname", e.Config.Name),
+		zap.String("epmethod", e.Config.Method),
+		zap.String("reqid", requestID(req.Context())),
+		zap.Stringer("middleware", mw),
+		zap.Int("status", response.StatusCode),
+	)
+
+	// The middleware's headers replace those set by the
+	// handles before, such as the request id or CORS headers
+	for key, values := range response.Header {
+		res.Header()[key] = append([]string(nil), values...)
+	}
+	res.WriteHeader(response.StatusCode)
+	io.Copy(res, response.Body)
+	return false
+}
@@ -0,0 +1,282 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// newMiddlewareEndpoint creates an endpoint calling HTTP middleware at the
+// URLs, in order, with the given failure policy and timeout.
+func newMiddlewareEndpoint(t *testing.T, onFailure string, timeout time.Duration, urls ...string) *Endpoint {
+	t.Helper()
+	e := &Endpoint{
+		Config:  &config.EndpointConfig{ID: 1, Name: "users", Method: "POST"},
+		Gateway: &config.GatewayConfig{MaxBodySizeBytes: 1 << 10},
+	}
+	for _, u := range urls {
+		mw, err := NewMiddleware(&config.MiddlewareConfig{URL: u, OnFailure: onFailure, TimeoutDuration: timeout})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Middleware = append(e.Middleware, mw)
+	}
+	return e
+}
+
+// backend stands in for the proxy, recording the body it receives.
+type backend struct {
+	calls int
+	body  string
+}
+
+func (b *backend) handle(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	b.calls++
+	body, _ := ioutil.ReadAll(req.Body)
+	b.body = string(body)
+	res.WriteHeader(http.StatusOK)
+}
+
+func TestMiddlewareReplaysBody(t *testing.T) {
+	var bodies []string
+	mw := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer mw.Close()
+
+	e := newMiddlewareEndpoint(t, config.MWFailClosed, time.Second, mw.URL, mw.URL)
+	b := &backend{}
+	res := httptest.NewRecorder()
+	e.middleware(b.handle)(res, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"jane"}`)), nil)
+
+	if res.Code != http.StatusOK || b.calls != 1 {
+		t.Fatalf("expected the request to reach the backend, got status %d", res.Code)
+	}
+	for _, body := range append(bodies, b.body) {
+		if body != `{"name":"jane"}` {
+			t.Errorf("expected the body to be replayed, got %q", body)
+		}
+	}
+	if len(bodies) != 2 {
+		t.Errorf("expected both middleware to be called, got %d calls", len(bodies))
+	}
+}
+
+func TestMiddlewareRejection(t *testing.T) {
+	var calls int32
+	rejecting := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		res.Header().Set("X-Reason", "no-tenant")
+		res.Header().Set("Vary", "Authorization")
+		res.WriteHeader(http.StatusForbidden)
+		res.Write([]byte("denied"))
+	}))
+	defer rejecting.Close()
+	next := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer next.Close()
+
+	e := newMiddlewareEndpoint(t, config.MWFailClosed, time.Second, rejecting.URL, next.URL)
+	b := &backend{}
+	res := httptest.NewRecorder()
+	// Set by the handles preceding the middleware
+	res.Header().Set("Vary", "Origin")
+	e.middleware(b.handle)(res, httptest.NewRequest(http.MethodPost, "/users", nil), nil)
+
+	if res.Code != http.StatusForbidden || res.Body.String() != "denied" || res.Header().Get("X-Reason") != "no-tenant" {
+		t.Errorf("expected the rejection to be returned verbatim, got %d %q %v", res.Code, res.Body.String(), res.Header())
+	}
+	if vary := res.Header()["Vary"]; len(vary) != 1 || vary[0] != "Authorization" {
+		t.Errorf("expected the middleware's header to replace the one set before, got %v", vary)
+	}
+	if calls != 1 || b.calls != 0 {
+		t.Errorf("expected the chain to stop at the rejection, got %d middleware and %d backend calls", calls, b.calls)
+	}
+}
+
+func TestMiddlewareRedirectsNotFollowed(t *testing.T) {
+	mw := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		http.Redirect(res, req, "https://login.example.com/", http.StatusFound)
+	}))
+	defer mw.Close()
+
+	e := newMiddlewareEndpoint(t, config.MWFailClosed, time.Second, mw.URL)
+	b := &backend{}
+	res := httptest.NewRecorder()
+	e.middleware(b.handle)(res, httptest.NewRequest(http.MethodPost, "/users", nil), nil)
+
+	if res.Code != http.StatusFound || res.Header().Get("Location") != "https://login.example.com/" || b.calls != 0 {
+		t.Errorf("expected the redirect to be sent to the client, got %d %v", res.Code, res.Header())
+	}
+}
+
+func TestMiddlewareFailures(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		onFailure string
+		status    int
+	}{
+		{"timeout, fail closed", slow.URL, config.MWFailClosed, http.StatusGatewayTimeout},
+		{"timeout, fail open", slow.URL, config.MWFailOpen, http.StatusOK},
+		{"unreachable, fail closed", down.URL, config.MWFailClosed, http.StatusBadGateway},
+		{"unreachable, fail open", down.URL, config.MWFailOpen, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		e := newMiddlewareEndpoint(t, test.onFailure, 50*time.Millisecond, test.url)
+		b := &backend{}
+		res := httptest.NewRecorder()
+		e.middleware(b.handle)(res, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader("body")), nil)
+
+		if res.Code != test.status {
+			t.Errorf("%s :: expected status %d, got %d", test.name, test.status, res.Code)
+		}
+		if reached := test.status == http.StatusOK; reached != (b.calls == 1) || (reached && b.body != "body") {
+			t.Errorf("%s :: expected the backend to be reached %t with the body, got %d calls with %q", test.name, reached, b.calls, b.body)
+		}
+	}
+}
+
+func TestMiddlewareBodyTooLarge(t *testing.T) {
+	var calls int32
+	mw := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer mw.Close()
+
+	e := newMiddlewareEndpoint(t, config.MWFailClosed, time.Second, mw.URL)
+	e.Gateway.MaxBodySizeBytes = 8
+
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		status        int
+	}{
+		{"at the limit", "12345678", 8, http.StatusOK},
+		{"declared too large", "123456789", 9, http.StatusRequestEntityTooLarge},
+		{"streamed too large", "123456789", -1, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		calls = 0
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(test.body))
+		req.ContentLength = test.contentLength
+		res := httptest.NewRecorder()
+		e.middleware((&backend{}).handle)(res, req, nil)
+
+		if res.Code != test.status {
+			t.Errorf("%s :: expected status %d, got %d", test.name, test.status, res.Code)
+		}
+		if want := test.status == http.StatusOK; want != (calls == 1) {
+			t.Errorf("%s :: expected the middleware to be called %t, got %d calls", test.name, want, calls)
+		}
+	}
+}
+
+func TestMutateHeaders(t *testing.T) {
+	mw := &Middleware{Config: &config.MiddlewareConfig{
+		HeaderPrefix:   "X-Hodor-",
+		MutableHeaders: []string{"X-User-Id", "X-Roles"},
+		ForwardHeaders: []string{"X-Tenant"},
+	}}
+
+	tests := []struct {
+		name     string
+		request  map[string][]string
+		response map[string][]string
+		want     map[string][]string
+	}{
+		{
+			"set and add",
+			map[string][]string{"X-Roles": {"client"}},
+			map[string][]string{"x-hodor-set-x-user-id": {"42"}, "X-Hodor-Add-X-Roles": {"admin"}},
+			map[string][]string{"X-User-Id": {"42"}, "X-Roles": {"client", "admin"}},
+		},
+		{
+			"remove, then set, then add",
+			map[string][]string{"X-Roles": {"client"}, "Cookie": {"a=1"}},
+			map[string][]string{"X-Hodor-Remove": {"x-roles, Cookie"}, "X-Hodor-Set-X-Roles": {"reader"}, "X-Hodor-Add-X-Roles": {"writer"}},
+			map[string][]string{"X-Roles": {"reader", "writer"}},
+		},
+		{
+			"headers that are not mutable",
+			map[string][]string{"Authorization": {"Bearer client"}},
+			map[string][]string{"X-Hodor-Set-Authorization": {"Bearer forged"}, "X-Hodor-Add-X-Admin": {"true"}},
+			map[string][]string{"Authorization": {"Bearer client"}},
+		},
+		{
+			"empty suffixes",
+			map[string][]string{},
+			map[string][]string{"X-Hodor-Set-": {"1"}, "X-Hodor-Add-": {"2"}},
+			map[string][]string{},
+		},
+		{
+			"forwarded headers",
+			map[string][]string{},
+			map[string][]string{"X-Tenant": {"acme"}, "X-Other": {"ignored"}},
+			map[string][]string{"X-Tenant": {"acme"}},
+		},
+	}
+
+	for _, test := range tests {
+		reqHeaders, mwHeaders := http.Header{}, http.Header{}
+		for key, values := range test.request {
+			reqHeaders[key] = values
+		}
+		// Header keys are canonicalised by the HTTP client reading them
+		for key, values := range test.response {
+			for _, value := range values {
+				mwHeaders.Add(key, value)
+			}
+		}
+
+		mw.mutateHeaders(mwHeaders, reqHeaders)
+		if fmt.Sprint(reqHeaders) != fmt.Sprint(http.Header(test.want)) {
+			t.Errorf("%s :: expected headers %v, got %v", test.name, test.want, reqHeaders)
+		}
+	}
+}
+
+func TestMiddlewareStripsClientHeaders(t *testing.T) {
+	// The middleware sets none of the headers on this request
+	mw := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	defer mw.Close()
+
+	e := newMiddlewareEndpoint(t, config.MWFailClosed, time.Second, mw.URL)
+	e.Middleware[0].Config.HeaderPrefix = "X-Hodor-"
+	e.Middleware[0].Config.MutableHeaders = []string{"X-User-Id"}
+	e.Middleware[0].Config.ForwardHeaders = []string{"X-Tenant"}
+
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("X-User-Id", "spoofed")
+	req.Header.Set("X-Tenant", "spoofed")
+
+	var backendHeaders http.Header
+	e.middleware(func(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		backendHeaders = req.Header
+	})(httptest.NewRecorder(), req, nil)
+
+	if backendHeaders.Get("X-User-Id") != "" || backendHeaders.Get("X-Tenant") != "" {
+		t.Errorf("expected the client's headers to be stripped, got %v", backendHeaders)
+	}
+}
@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/logging"
+	"github.com/saidmithilesh/hodor/ratelimit"
+	"go.uber.org/zap"
+)
+
+// rateLimitKeyAll is the key under which requests are counted together,
+// either because the limiter counts all requests or because a request is
+// missing the key the limiter counts by.
+const rateLimitKeyAll = "*"
+
+// rateLimit wraps the handle with the endpoint's rate limiters. Requests
+// exceeding any of the limits are rejected with a 429 status and a
+// Retry-After header telling the client how many seconds to wait before
+// retrying. The limiters are checked in order and a rejected request is
+// not counted by the limiters following the one that rejected it.
+func (e *Endpoint) rateLimit(next httprouter.Handle) httprouter.Handle {
+	if len(e.Limiters) == 0 {
+		return next
+	}
+
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		for _, limiter := range e.Limiters {
+			result, err := limiter.Allow(e.rateLimitKey(limiter, req, params))
+			if err != nil {
+				logging.Logger.Error(
+					"Rate limiter store failed",
+					zap.Uint("epid", e.Config.ID),
+					zap.String("epname", e.Config.Name),
+					zap.String("epmethod", e.Config.Method),
+					zap.String("reqid", requestID(req.Context())),
+					zap.String("limiter", limiter.Name),
+					zap.Error(err),
+				)
+			}
+
+			if result.Allowed {
+				continue
+			}
+
+			if e.Metrics != nil {
+				e.Metrics.RateLimited.With(e.metricLabels(limiter.Name)...).Inc()
+			}
+			logging.Logger.Info(
+				"Request rate limited",
+				zap.Uint("epid", e.Config.ID),
+				zap.String("epname", e.Config.Name),
+				zap.String("epmethod", e.Config.Method),
+				zap.String("reqid", requestID(req.Context())),
+				zap.String("limiter", limiter.Name),
+				zap.Duration("retryAfter", result.RetryAfter),
+			)
+
+			seconds := int64(math.Ceil(result.RetryAfter.Seconds()))
+			res.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+			res.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(res, "Too many requests")
+			return
+		}
+
+		next(res, req, params)
+	}
+}
+
+// rateLimitKey derives the key under which the limiter counts the request.
+func (e *Endpoint) rateLimitKey(limiter *ratelimit.Limiter, req *http.Request, params httprouter.Params) string {
+	var key string
+
+	switch limiter.KeyType {
+	case config.RLKeyIP:
+		key = e.clientIP(req)
+
+	case config.RLKeyConsumer:
+		consumer, ok := ConsumerFromContext(req.Context())
+		if !ok {
+			return config.RLKeyIP + ":" + e.clientIP(req)
+		}
+		key = consumer
+
+	case config.RLKeyHeader:
+		// Header values often are credentials, so
+		// avoid keeping them in the store verbatim
+		if value := req.Header.Get(limiter.KeyName); value != "" {
+			sum := sha256.Sum256([]byte(value))
+			key = hex.EncodeToString(sum[:16])
+		}
+
+	case config.RLKeyParam:
+		key = params.ByName(limiter.KeyName)
+	}
+
+	if key == "" {
+		return rateLimitKeyAll
+	}
+	return limiter.KeyType + ":" + key
+}
+
+// clientIP returns the IP address of the client that sent the request.
+// X-Forwarded-For is only consulted when the request comes from a trusted
+// proxy, in which case the addresses it lists are walked from the nearest
+// one until reaching an address that is not a trusted proxy itself.
+func (e *Endpoint) clientIP(req *http.Request) string {
+	remoteAddr, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteAddr = req.RemoteAddr
+	}
+
+	if !e.isTrustedProxy(remoteAddr) {
+		return remoteAddr
+	}
+
+	var forwarded []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	clientIP := remoteAddr
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		clientIP = ip
+		if !e.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return clientIP
+}
+
+func (e *Endpoint) isTrustedProxy(addr string) bool {
+	if e.Gateway == nil {
+		return false
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range e.Gateway.TrustedProxyNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/ratelimit"
+)
+
+func TestRateLimitStopsAtFirstRejection(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	strict := &ratelimit.Limiter{Name: "strict", KeyType: config.RLKeyAll, Requests: 1, Window: time.Hour, Penalty: time.Minute, Store: store}
+	loose := &ratelimit.Limiter{Name: "loose", KeyType: config.RLKeyAll, Requests: 2, Window: time.Hour, Store: store}
+	e := &Endpoint{
+		Config:   &config.EndpointConfig{ID: 1, Name: "users", Method: "GET"},
+		Limiters: []*ratelimit.Limiter{strict, loose},
+	}
+
+	handle := e.rateLimit(func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		res.WriteHeader(http.StatusOK)
+	})
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		res := httptest.NewRecorder()
+		handle(res, httptest.NewRequest(http.MethodGet, "/users", nil), nil)
+		if res.Code != want {
+			t.Fatalf("request %d :: expected status %d, got %d", i, want, res.Code)
+		}
+		if want == http.StatusTooManyRequests && res.Header().Get("Retry-After") != "60" {
+			t.Errorf("request %d :: expected to retry after the penalty of 60s, got '%s'", i, res.Header().Get("Retry-After"))
+		}
+	}
+
+	// Only the request allowed by the strict limiter has been counted
+	// by the loose one
+	if result, _ := loose.Allow(rateLimitKeyAll); !result.Allowed {
+		t.Errorf("expected the loose limiter to have counted a single request")
+	}
+}
+
+// trustedProxies builds a gateway config trusting the given networks.
+func trustedProxies(t *testing.T, cidrs ...string) *config.GatewayConfig {
+	t.Helper()
+	gc := &config.GatewayConfig{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gc.TrustedProxyNets = append(gc.TrustedProxyNets, ipNet)
+	}
+	return gc
+}
+
+func TestClientIP(t *testing.T) {
+	e := &Endpoint{Gateway: trustedProxies(t, "10.0.0.0/8", "fd00::/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"no proxy", "203.0.113.7:4242", nil, "203.0.113.7"},
+		{"untrusted remote address", "203.0.113.7:4242", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:4242", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed leftmost entry", "10.0.0.1:4242", []string{"192.0.2.66, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:4242", []string{"192.0.2.66, 198.51.100.1, 10.0.0.3", "10.0.0.2"}, "198.51.100.1"},
+		{"only trusted proxies", "10.0.0.1:4242", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"invalid entry", "10.0.0.1:4242", []string{"198.51.100.1, not-an-ip, 10.0.0.2"}, "10.0.0.2"},
+		{"missing header", "10.0.0.1:4242", nil, "10.0.0.1"},
+		{"ipv6", "[fd00::1]:4242", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"no port", "203.0.113.7", nil, "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = test.remoteAddr
+		for _, value := range test.forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+
+		if got := e.clientIP(req); got != test.want {
+			t.Errorf("%s :: expected client IP '%s', got '%s'", test.name, test.want, got)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	e := &Endpoint{Gateway: trustedProxies(t, "10.0.0.0/8")}
+
+	tests := []struct {
+		name     string
+		keyType  string
+		keyName  string
+		header   string
+		consumer string
+		params   httprouter.Params
+		want     string
+	}{
+		{"all", config.RLKeyAll, "", "", "", nil, rateLimitKeyAll},
+		{"ip", config.RLKeyIP, "", "", "", nil, "ip:198.51.100.1"},
+		{"consumer", config.RLKeyConsumer, "", "", "partner-a", nil, "consumer:partner-a"},
+		{"missing consumer", config.RLKeyConsumer, "", "", "", nil, "ip:198.51.100.1"},
+		// Header values are counted by the first 16 bytes of their SHA-256
+		{"header", config.RLKeyHeader, "X-Api-Key", "secret-key", "", nil, "header:85dbe15d75ef9308c7ae0f33c7a324cc"},
+		{"missing header", config.RLKeyHeader, "X-Api-Key", "", "", nil, rateLimitKeyAll},
+		{"param", config.RLKeyParam, "tenant", "", "", httprouter.Params{{Key: "tenant", Value: "acme"}}, "param:acme"},
+		{"missing param", config.RLKeyParam, "tenant", "", "", nil, rateLimitKeyAll},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = "10.0.0.1:4242"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		if test.header != "" {
+			req.Header.Set(test.keyName, test.header)
+		}
+		if test.consumer != "" {
+			req = req.WithContext(WithConsumer(req.Context(), test.consumer))
+		}
+
+		limiter := &ratelimit.Limiter{KeyType: test.keyType, KeyName: test.keyName}
+		if got := e.rateLimitKey(limiter, req, test.params); got != test.want {
+			t.Errorf("%s :: expected key '%s', got '%s'", test.name, test.want, got)
+		}
+	}
+}
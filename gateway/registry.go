@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// MiddlewareFunc is a native Go middleware. It receives the next handler
+// in the chain and returns a handler that either passes the request on to
+// it or writes a response of its own to stop the request from going any
+// further. The route's parameters are available through
+// httprouter.ParamsFromContext.
+type MiddlewareFunc func(http.Handler) http.Handler
+
+var middlewareRegistry = struct {
+	sync.RWMutex
+	middleware map[string]MiddlewareFunc
+}{middleware: make(map[string]MiddlewareFunc)}
+
+// RegisterMiddleware makes a native middleware available under the given
+// name, so that the gateway and endpoint middleware chains in the config
+// file can reference it with 'name: <name>'. Middleware must be registered
+// before the gateway is built. Registering the same name twice panics.
+func RegisterMiddleware(name string, mw MiddlewareFunc) {
+	middlewareRegistry.Lock()
+	defer middlewareRegistry.Unlock()
+
+	if mw == nil {
+		panic("gateway: RegisterMiddleware middleware is nil")
+	}
+	if _, exists := middlewareRegistry.middleware[name]; exists {
+		panic(fmt.Sprintf("gateway: RegisterMiddleware called twice for middleware '%s'", name))
+	}
+	middlewareRegistry.middleware[name] = mw
+}
+
+// LookupMiddleware returns the native middleware registered under the
+// given name, if any.
+func LookupMiddleware(name string) (MiddlewareFunc, bool) {
+	middlewareRegistry.RLock()
+	defer middlewareRegistry.RUnlock()
+
+	mw, ok := middlewareRegistry.middleware[name]
+	return mw, ok
+}
+
+// MiddlewareRegistered reports whether a native middleware is registered
+// under the given name. Pass it to config.WithMiddlewareLookup so that
+// middleware referenced by an unknown name fail validation.
+func MiddlewareRegistered(name string) bool {
+	_, ok := LookupMiddleware(name)
+	return ok
+}
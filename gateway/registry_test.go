@@ -0,0 +1,17 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMiddlewareRegistered(t *testing.T) {
+	RegisterMiddleware("test-registered", func(next http.Handler) http.Handler { return next })
+
+	if !MiddlewareRegistered("test-registered") {
+		t.Errorf("expected the registered middleware to be known")
+	}
+	if MiddlewareRegistered("test-unregistered") {
+		t.Errorf("expected an unregistered middleware to be unknown")
+	}
+}
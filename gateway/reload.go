@@ -0,0 +1,220 @@
+package gateway
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/logging"
+	"go.uber.org/zap"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// restartSettings are the gateway settings that only take effect when
+// the gateway starts. Changing them requires a restart.
+var restartSettings = map[string]bool{
+	"port":            true,
+	"enable_TLS":      true,
+	"TLS_cert":        true,
+	"TLS_key":         true,
+	"admin_port":      true,
+	"enable_metrics":  true,
+	"log_level":       true,
+	"log_output":      true,
+	"log_credentials": true,
+	"log_file":        true,
+	"access_log":      true,
+}
+
+// ServeHTTP dispatches requests to the router built from the config in
+// use, so that the gateway can be served by any http.Server.
+func (g *Gateway) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	g.active().Router.ServeHTTP(res, req)
+}
+
+// active returns the gateway built from the config in use. That is the
+// gateway itself until the config is reloaded.
+func (g *Gateway) active() *Gateway {
+	if active, ok := g.current.Load().(*Gateway); ok {
+		return active
+	}
+	return g
+}
+
+// Reload loads the config file again, builds a fresh router from it and
+// swaps it in for the requests received from then on. Requests in flight
+// complete with the config they started with. A config that fails
+// validation or cannot be built is rejected and the gateway keeps running
+// with the config in use. The rate limiter store, the metrics and the
+// tracer are kept, along with their counters and buffered spans.
+func (g *Gateway) Reload(trigger string) {
+	current := g.active()
+	path := current.Config.ConfigFilePath
+	logging.Logger.Info("Reloading config", zap.String("path", path), zap.String("trigger", trigger))
+
+	conf, err := config.Load(path, config.WithMiddlewareLookup(MiddlewareRegistered))
+	if errs, ok := err.(config.ValidationErrors); ok {
+		logging.Logger.Error("Config reload rejected", zap.String("path", path), zap.Reflect("errors", []*config.ValidationError(errs)))
+		return
+	} else if err != nil {
+		logging.Logger.Error("Config reload rejected", zap.String("path", path), zap.Error(err))
+		return
+	}
+
+	next := &Gateway{Store: g.Store, Metrics: g.Metrics, Tracer: g.Tracer}
+	if err := next.build(conf); err != nil {
+		logging.Logger.Error(
+			"Config reload rejected",
+			append([]zap.Field{zap.String("path", path), zap.String("cause", err.message)}, err.fields...)...,
+		)
+		return
+	}
+	g.current.Store(next)
+
+	changed, restart := diffGateway(&current.Config.Gateway, &conf.Gateway)
+	added, removed, modified := diffEndpoints(current.Config.Gateway.Endpoints, conf.Gateway.Endpoints)
+	logging.Logger.Info(
+		"Config reloaded",
+		zap.String("path", path),
+		zap.Strings("changedSettings", changed),
+		zap.Strings("addedEndpoints", added),
+		zap.Strings("removedEndpoints", removed),
+		zap.Strings("changedEndpoints", modified),
+	)
+	if len(restart) > 0 {
+		logging.Logger.Warn("Some changed settings only take effect on restart", zap.Strings("settings", restart))
+	}
+}
+
+// watchConfig polls the files of the config in use, the gateway file and
+// those it includes, and signals on the returned channel whenever a file
+// is added or removed or the modification time or size of one changes.
+func (g *Gateway) watchConfig() <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	if g.Config.ConfigFilePath == "" {
+		return changes
+	}
+
+	go func() {
+		last := statFiles(g.Config.Files())
+		for range time.Tick(configPollInterval) {
+			current := statFiles(g.active().Config.Files())
+			if reflect.DeepEqual(current, last) {
+				continue
+			}
+			last = current
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes
+}
+
+// fileState is the modification time and size of a file
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFiles returns the state of the files, leaving out those which
+// cannot be read.
+func statFiles(files []string) map[string]fileState {
+	states := make(map[string]fileState, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			states[file] = fileState{info.ModTime(), info.Size()}
+		}
+	}
+	return states
+}
+
+// diffGateway returns the gateway settings, named after their YAML keys,
+// that differ between the configs, and those of them that require a
+// restart. Endpoints are compared separately.
+func diffGateway(old *config.GatewayConfig, new *config.GatewayConfig) ([]string, []string) {
+	var changed, restart []string
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		key := strings.Split(oldValue.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" || key == "endpoints" {
+			continue
+		}
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+
+		changed = append(changed, key)
+		if restartSettings[key] {
+			restart = append(restart, key)
+		}
+	}
+
+	if !reflect.DeepEqual(old.LogCredentials, new.LogCredentials) {
+		restart = append(restart, "log_credentials")
+	}
+	if !reflect.DeepEqual(old.RateLimit.Store, new.RateLimit.Store) {
+		restart = append(restart, "rate_limit.store")
+	}
+	// Sample ratios are read from the config in use, the rest of the
+	// tracing settings by the tracer created on start
+	oldTracing, newTracing := old.Tracing, new.Tracing
+	oldTracing.SampleRatio, newTracing.SampleRatio = nil, nil
+	if !reflect.DeepEqual(oldTracing, newTracing) {
+		restart = append(restart, "tracing")
+	}
+
+	return changed, dedupe(restart)
+}
+
+// diffEndpoints returns the endpoints added, removed and changed between
+// the configs, named by their method and path. Those are unique to each
+// endpoint, unlike ids which may be left out.
+func diffEndpoints(old []config.EndpointConfig, new []config.EndpointConfig) ([]string, []string, []string) {
+	oldByRoute := make(map[string]*config.EndpointConfig, len(old))
+	for i := range old {
+		oldByRoute[endpointRoute(&old[i])] = &old[i]
+	}
+
+	var added, removed, changed []string
+	for i := range new {
+		route := endpointRoute(&new[i])
+		previous, ok := oldByRoute[route]
+		switch {
+		case !ok:
+			added = append(added, route)
+		case !reflect.DeepEqual(*previous, new[i]):
+			changed = append(changed, route)
+		}
+		delete(oldByRoute, route)
+	}
+	for route := range oldByRoute {
+		removed = append(removed, route)
+	}
+	sort.Strings(removed)
+
+	return added, removed, changed
+}
+
+func endpointRoute(e *config.EndpointConfig) string {
+	return e.Method + " " + e.Path
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
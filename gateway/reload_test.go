@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// testReloadConfig configures a single endpoint whose method, path and
+// backend are provided as format arguments.
+const testReloadConfig = `gateway:
+  id: 1
+  name: "test"
+  port: ":8080"
+  endpoints:
+  - name: "orders"
+    method: %s
+    path: %s
+    backend: %q
+`
+
+func TestDiffEndpoints(t *testing.T) {
+	// Endpoints without ids are told apart by their method and path
+	old := []config.EndpointConfig{
+		{Method: "GET", Path: "/orders", Backend: "http://orders"},
+		{Method: "GET", Path: "/users", Backend: "http://users"},
+		{Method: "DELETE", Path: "/users", Backend: "http://users"},
+	}
+	new := []config.EndpointConfig{
+		{Method: "GET", Path: "/users", Backend: "http://users-v2"},
+		{Method: "POST", Path: "/orders", Backend: "http://orders"},
+		{Method: "DELETE", Path: "/users", Backend: "http://users"},
+	}
+
+	added, removed, changed := diffEndpoints(old, new)
+	if fmt.Sprint(added) != "[POST /orders]" {
+		t.Errorf("expected endpoints [POST /orders] to be added, got %v", added)
+	}
+	if fmt.Sprint(removed) != "[GET /orders]" {
+		t.Errorf("expected endpoints [GET /orders] to be removed, got %v", removed)
+	}
+	if fmt.Sprint(changed) != "[GET /users]" {
+		t.Errorf("expected endpoints [GET /users] to be changed, got %v", changed)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(res, req.URL.Path)
+	}))
+	defer backend.Close()
+
+	dir, err := ioutil.TempDir("", "hodor-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	writeConfig := func(method string, route string) {
+		content := fmt.Sprintf(testReloadConfig, method, route, backend.URL)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("GET", "/v1")
+	conf, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("invalid test config :: %s", err)
+	}
+	g := &Gateway{}
+	if err := g.build(conf); err != nil {
+		t.Fatalf("failed to build the gateway :: %s", err)
+	}
+
+	serves := func(route string) bool {
+		res := httptest.NewRecorder()
+		g.ServeHTTP(res, httptest.NewRequest(http.MethodGet, route, nil))
+		return res.Code == http.StatusOK && res.Body.String() == route
+	}
+
+	// A config failing validation is rejected
+	writeConfig("FETCH", "/v2")
+	g.Reload("test")
+	if g.active() != g || !serves("/v1") || serves("/v2") {
+		t.Errorf("expected the previous config to be kept")
+	}
+
+	writeConfig("GET", "/v2")
+	g.Reload("test")
+	if !serves("/v2") || serves("/v1") {
+		t.Errorf("expected the valid config to be swapped in")
+	}
+}
@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/julienschmidt/httprouter"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// requestIDRegex matches the request ids accepted from clients. Anything
+// else is replaced, so that ids are safe to log and forward.
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._~:/+=-]{1,128}$`)
+
+// identify wraps the chain of handles of the endpoint. It reuses the id a
+// request comes with in the request id header if it is valid, or generates
+// one otherwise. The id is forwarded to the middleware and the backend in
+// the same header, echoed back to the client and attached to the request
+// context along with the rest of the info collected along the chain.
+func (e *Endpoint) identify(next httprouter.Handle) httprouter.Handle {
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		header := e.Gateway.RequestIDHeader
+
+		id := req.Header.Get(header)
+		if !requestIDRegex.MatchString(id) {
+			id = uuid.NewV4().String()
+		}
+		req.Header.Set(header, id)
+		res.Header().Set(header, id)
+
+		info := &requestInfo{ID: id}
+		next(res, req.WithContext(withRequestInfo(req.Context(), info)), params)
+	}
+}
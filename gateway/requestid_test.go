@@ -0,0 +1,39 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDUnmatchedRequests(t *testing.T) {
+	g, _ := newTestGateway(t)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/users", nil)
+	preflight.Header.Set("Origin", "https://example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"not found", httptest.NewRequest(http.MethodGet, "/missing", nil)},
+		{"method not allowed", httptest.NewRequest(http.MethodPost, "/users", nil)},
+		{"preflight", preflight},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		g.ServeHTTP(res, test.req)
+		if id := res.Header().Get("X-Request-ID"); !requestIDRegex.MatchString(id) {
+			t.Errorf("%s :: expected a generated request id, got '%s'", test.name, id)
+		}
+
+		test.req.Header.Set("X-Request-ID", "client-id")
+		res = httptest.NewRecorder()
+		g.ServeHTTP(res, test.req)
+		if id := res.Header().Get("X-Request-ID"); id != "client-id" {
+			t.Errorf("%s :: expected the client request id to be echoed, got '%s'", test.name, id)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/saidmithilesh/hodor/logging"
+	"go.uber.org/zap"
+)
+
+// Shutdown stops the gateway gracefully. New connections are refused
+// right away and the health check reports the gateway as not ready while
+// the requests in flight complete, for at most the shutdown timeout.
+// Connections still open after that are closed. Spans are exported
+// before returning, logs are left for the caller to flush.
+func (g *Gateway) Shutdown() {
+	atomic.StoreInt32(&g.draining, 1)
+
+	timeout := g.active().Config.Gateway.ShutdownTimeoutDuration
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := g.server.Shutdown(ctx); err != nil {
+		logging.Logger.Warn(
+			"Requests still in flight after the shutdown timeout, closing their connections",
+			zap.Duration("timeout", timeout),
+			zap.Error(err),
+		)
+		g.server.Close()
+	} else {
+		logging.Logger.Info("Requests in flight drained", zap.Duration("took", time.Since(start)))
+	}
+
+	if err := g.Tracer.Sync(); err != nil {
+		logging.Logger.Warn("Error while exporting spans", zap.Error(err))
+	}
+	g.Store.Close()
+
+	if g.admin != nil {
+		g.admin.Close()
+	}
+}
+
+// live answers liveness checks, as long as the gateway is running.
+func (g *Gateway) live(res http.ResponseWriter, req *http.Request) {
+	fmt.Fprintf(res, "OK")
+}
+
+// ready answers readiness checks. The gateway stops being ready once it
+// starts shutting down.
+func (g *Gateway) ready(res http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&g.draining) == 1 {
+		res.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(res, "Draining")
+		return
+	}
+	fmt.Fprintf(res, "OK")
+}
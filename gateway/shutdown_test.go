@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		fmt.Fprint(res, "done")
+	}))
+	defer backend.Close()
+
+	conf, err := config.Parse([]byte(fmt.Sprintf(testReloadConfig, "GET", "/orders", backend.URL) + "  shutdown_timeout: \"5S\"\n"))
+	if err != nil {
+		t.Fatalf("invalid test config :: %s", err)
+	}
+	g := &Gateway{}
+	g.buildStore(&conf.Gateway.RateLimit.Store)
+	if err := g.build(conf); err != nil {
+		t.Fatalf("failed to build the gateway :: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.server = &http.Server{Handler: g}
+	go g.server.Serve(listener)
+
+	ready := func() int {
+		res := httptest.NewRecorder()
+		g.ready(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return res.Code
+	}
+	if status := ready(); status != http.StatusOK {
+		t.Errorf("expected the gateway to be ready before shutting down, got %d", status)
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String() + "/orders")
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		inFlight <- result{string(body), err}
+	}()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		g.Shutdown()
+		close(stopped)
+	}()
+	for deadline := time.Now().Add(2 * time.Second); atomic.LoadInt32(&g.draining) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if status := ready(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected the gateway not to be ready while draining, got %d", status)
+	}
+	select {
+	case <-stopped:
+		t.Fatalf("expected the shutdown to wait for the request in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if r := <-inFlight; r.err != nil || r.body != "done" {
+		t.Errorf("expected the request in flight to complete, got '%s' and %v", r.body, r.err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Errorf("expected the shutdown to complete once the request in flight has")
+	}
+}
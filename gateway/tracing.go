@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/saidmithilesh/hodor/tracing"
+)
+
+// trace wraps the handle to record the server span of every request. The
+// calls to the middleware and the backend are recorded as its children.
+func (e *Endpoint) trace(next httprouter.Handle) httprouter.Handle {
+	if e.Tracer == nil {
+		return next
+	}
+
+	ratio := e.Gateway.SampleRatioFor(e.Config)
+	name := e.Config.Method + " " + e.Config.Path
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		ctx, span := e.Tracer.StartServer(req.Context(), name, req.Header, ratio)
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("http.route", e.Config.Path)
+		span.SetAttribute("url.path", req.URL.Path)
+		span.SetAttribute("client.address", e.clientIP(req))
+		span.SetAttribute("user_agent.original", req.UserAgent())
+		span.SetAttribute("hodor.request.id", requestID(ctx))
+		span.SetAttribute("hodor.endpoint.id", int64(e.Config.ID))
+		span.SetAttribute("hodor.endpoint.name", e.Config.Name)
+
+		recorder := &responseRecorder{ResponseWriter: res}
+		next(recorder, req.WithContext(ctx), params)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		// The consumer is attached to the request info shared along the
+		// chain, as the authenticated request's context is not seen here
+		if info := requestInfoFromContext(ctx); info != nil && info.Consumer != "" {
+			span.SetAttribute("enduser.id", info.Consumer)
+		}
+		span.SetAttribute("http.response.status_code", recorder.status)
+		if recorder.status >= 500 {
+			span.SetError(http.StatusText(recorder.status))
+		}
+		span.End()
+	}
+}
+
+// endClientSpan records the outcome of a call to a middleware or the
+// backend and ends its span.
+func endClientSpan(span *tracing.Span, response *http.Response, err error) {
+	if err != nil {
+		span.SetError(err.Error())
+	} else {
+		span.SetAttribute("http.response.status_code", response.StatusCode)
+		if response.StatusCode >= 500 {
+			span.SetError(http.StatusText(response.StatusCode))
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/saidmithilesh/hodor/auth"
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/tracing"
+)
+
+// testTracingConfig configures an endpoint authenticated by API key and
+// traced to the collector and with the consumers file provided as format
+// arguments.
+const testTracingConfig = `gateway:
+  id: 1
+  name: "test"
+  port: ":8080"
+  consumers_file: %q
+  tracing:
+    enable: true
+    endpoint: %q
+  endpoints:
+  - id: 1
+    name: "orders"
+    method: GET
+    path: /orders
+    backend: "http://127.0.0.1:1"
+    auth:
+      enable: true
+      type: API_KEY
+`
+
+func TestTraceConsumer(t *testing.T) {
+	var mu sync.Mutex
+	var spans []map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]interface{} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range body.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	dir, err := ioutil.TempDir("", "hodor-tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	consumers := filepath.Join(dir, "consumers.yml")
+	content := fmt.Sprintf("consumers:\n- name: \"partner-a\"\n  api_keys:\n  - %q\n", auth.HashKey("secret-key"))
+	if err := ioutil.WriteFile(consumers, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := config.Parse([]byte(fmt.Sprintf(testTracingConfig, consumers, collector.URL)))
+	if err != nil {
+		t.Fatalf("invalid test config :: %s", err)
+	}
+	g := &Gateway{Tracer: tracing.New(&conf.Gateway)}
+	if err := g.build(conf); err != nil {
+		t.Fatalf("failed to build the gateway :: %s", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("X-Api-Key", "secret-key")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+	if err := g.Tracer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var consumer interface{}
+	for _, span := range spans {
+		if span["name"] != "GET /orders" {
+			continue
+		}
+		attributes, _ := span["attributes"].([]interface{})
+		for _, attribute := range attributes {
+			if attr, _ := attribute.(map[string]interface{}); attr["key"] == "enduser.id" {
+				consumer = attr["value"].(map[string]interface{})["stringValue"]
+			}
+		}
+	}
+	if consumer != "partner-a" {
+		t.Errorf("expected the server span to carry enduser.id 'partner-a', got %v in %v", consumer, spans)
+	}
+}
@@ -0,0 +1,180 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// combinedTimeFormat is the time format of the Apache combined log format
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog is a singleton instance of type AccessLogger. It is nil if
+// access logging is disabled.
+var AccessLog *AccessLogger
+
+// AccessEntry describes a request that has been responded to. It is the
+// data access log templates are executed with.
+type AccessEntry struct {
+	Time            time.Time
+	RequestID       string
+	EndpointID      uint
+	EndpointName    string
+	Method          string
+	URI             string
+	Proto           string
+	Status          int
+	Latency         time.Duration
+	UpstreamLatency time.Duration
+	BytesIn         int64
+	BytesOut        int64
+	ClientIP        string
+	UserAgent       string
+	Referer         string
+	Consumer        string
+}
+
+// AccessLogger writes an access log line per request in the configured
+// format. Access log lines are never sampled, unlike application logs.
+type AccessLogger struct {
+	Format   string
+	Template *template.Template
+
+	// logger writes JSON lines, and the lines of other formats when
+	// they go along with the application logs as messages
+	logger *zap.Logger
+	// writer receives the lines of formats other than JSON when they
+	// go to a sink of their own
+	writer zapcore.WriteSyncer
+}
+
+// buildAccessLog constructs the access logger. Access log lines go to the
+// application log sink, with the same encoder, unless a separate output
+// has been configured for them.
+func buildAccessLog(conf *config.Config, encoder zapcore.Encoder, sink zapcore.WriteSyncer) error {
+	al := &conf.Gateway.AccessLog
+	accessLog := &AccessLogger{Format: al.Format}
+
+	if al.Format == config.AccessLogTemplate {
+		tmpl, err := template.New("access_log").Parse(al.Template)
+		if err != nil {
+			return err
+		}
+		accessLog.Template = tmpl
+	}
+
+	switch al.Output {
+	case config.LogOutputStdio:
+		sink = zapcore.Lock(os.Stdout)
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+		accessLog.writer = sink
+
+	case config.LogOutputFile:
+		fs, err := NewFileSink(&al.File)
+		if err != nil {
+			return err
+		}
+		reopenOnSignal(fs)
+		sink = fs
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+		accessLog.writer = sink
+	}
+
+	accessLog.logger = zap.New(
+		zapcore.NewCore(encoder, sink, zapcore.InfoLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+		gatewayFields(conf),
+	)
+
+	AccessLog = accessLog
+	return nil
+}
+
+// Log writes the access log line of a request.
+func (al *AccessLogger) Log(entry *AccessEntry) {
+	if al.Format == config.AccessLogJSON {
+		al.logger.Info(
+			"Request completed",
+			zap.String("reqid", entry.RequestID),
+			zap.Uint("epid", entry.EndpointID),
+			zap.String("epname", entry.EndpointName),
+			zap.String("method", entry.Method),
+			zap.String("uri", entry.URI),
+			zap.String("proto", entry.Proto),
+			zap.Int("status", entry.Status),
+			zap.Duration("latency", entry.Latency),
+			zap.Duration("upstreamLatency", entry.UpstreamLatency),
+			zap.Int64("bytesIn", entry.BytesIn),
+			zap.Int64("bytesOut", entry.BytesOut),
+			zap.String("clientIp", entry.ClientIP),
+			zap.String("userAgent", entry.UserAgent),
+			zap.String("referer", entry.Referer),
+			zap.String("consumer", entry.Consumer),
+		)
+		return
+	}
+
+	var line bytes.Buffer
+	if al.Format == config.AccessLogTemplate {
+		if err := al.Template.Execute(&line, entry); err != nil {
+			Logger.Error("Error while executing access log template", zap.String("reqid", entry.RequestID), zap.Error(err))
+			return
+		}
+	} else {
+		combined(&line, entry)
+	}
+
+	if al.writer == nil {
+		al.logger.Info(line.String())
+		return
+	}
+	line.WriteByte('\n')
+	al.writer.Write(line.Bytes())
+}
+
+// Sync flushes the access log lines buffered by the sink.
+func (al *AccessLogger) Sync() error {
+	if al.writer != nil {
+		return al.writer.Sync()
+	}
+	return al.logger.Sync()
+}
+
+// combined formats the entry in the Apache combined log format.
+//
+//	%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func combined(line *bytes.Buffer, entry *AccessEntry) {
+	line.WriteString(entry.ClientIP)
+	line.WriteString(" - ")
+	line.WriteString(orDash(entry.Consumer))
+	line.WriteString(" [")
+	line.WriteString(entry.Time.Format(combinedTimeFormat))
+	line.WriteString("] ")
+	line.WriteString(strconv.Quote(entry.Method + " " + entry.URI + " " + entry.Proto))
+	line.WriteByte(' ')
+	line.WriteString(strconv.Itoa(entry.Status))
+	line.WriteByte(' ')
+	if entry.BytesOut > 0 {
+		line.WriteString(strconv.FormatInt(entry.BytesOut, 10))
+	} else {
+		line.WriteByte('-')
+	}
+	line.WriteByte(' ')
+	line.WriteString(strconv.Quote(orDash(entry.Referer)))
+	line.WriteByte(' ')
+	line.WriteString(strconv.Quote(orDash(entry.UserAgent)))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
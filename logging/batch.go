@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// logLine is a single encoded log line, without its trailing newline, and
+// the time it has been written at.
+type logLine struct {
+	data []byte
+	time time.Time
+}
+
+// batchSink is the base of the sinks shipping log lines to remote services.
+// Lines are queued in a bounded buffer and shipped in batches by a
+// background goroutine, so that logging never blocks on the network. Lines
+// are dropped and counted when the buffer is full or a batch cannot be
+// shipped.
+type batchSink struct {
+	// accessed atomically, kept first for 64 bit alignment
+	dropped uint64
+
+	// name of the remote service, used in error messages
+	name          string
+	ship          func(batch []logLine) (int, error)
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+
+	lines chan logLine
+	syncs chan chan struct{}
+}
+
+// newBatchSink creates a batchSink and starts shipping the lines written
+// to it with ship, which returns the number of lines it failed to ship.
+func newBatchSink(name string, conf *config.LogBatchConfig, ship func([]logLine) (int, error)) *batchSink {
+	bs := &batchSink{
+		name:          name,
+		ship:          ship,
+		batchSize:     conf.BatchSize,
+		flushInterval: conf.FlushIntervalDuration,
+		timeout:       conf.TimeoutDuration,
+		lines:         make(chan logLine, conf.BufferSize),
+		syncs:         make(chan chan struct{}),
+	}
+	go bs.run()
+	return bs
+}
+
+// Write implements zapcore.WriteSyncer. It never blocks, the line is
+// dropped if the buffer is full.
+func (bs *batchSink) Write(p []byte) (int, error) {
+	line := logLine{
+		data: append([]byte(nil), bytes.TrimRight(p, "\n")...),
+		time: time.Now(),
+	}
+
+	select {
+	case bs.lines <- line:
+	default:
+		atomic.AddUint64(&bs.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer. It waits for the buffered lines to
+// be shipped, for at most the configured timeout.
+func (bs *batchSink) Sync() error {
+	done := make(chan struct{})
+	timeout := time.After(bs.timeout)
+
+	select {
+	case bs.syncs <- done:
+	case <-timeout:
+		return errors.New("timed out flushing logs to " + bs.name)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-timeout:
+		return errors.New("timed out flushing logs to " + bs.name)
+	}
+}
+
+// Dropped returns the number of log lines dropped so far.
+func (bs *batchSink) Dropped() uint64 {
+	return atomic.LoadUint64(&bs.dropped)
+}
+
+func (bs *batchSink) run() {
+	ticker := time.NewTicker(bs.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]logLine, 0, bs.batchSize)
+	var reported uint64
+	for {
+		select {
+		case line := <-bs.lines:
+			batch = append(batch, line)
+			if len(batch) >= bs.batchSize {
+				batch = bs.flush(batch)
+			}
+
+		case <-ticker.C:
+			batch = bs.flush(batch)
+
+			// The logger cannot be used to report on itself
+			if dropped := bs.Dropped(); dropped != reported {
+				fmt.Fprintf(os.Stderr, "Dropped %d log lines shipped to %s so far\n", dropped, bs.name)
+				reported = dropped
+			}
+
+		case done := <-bs.syncs:
+			for pending := len(bs.lines); pending > 0; pending-- {
+				batch = append(batch, <-bs.lines)
+				if len(batch) >= bs.batchSize {
+					batch = bs.flush(batch)
+				}
+			}
+			batch = bs.flush(batch)
+			close(done)
+		}
+	}
+}
+
+// flush ships the batch and returns it emptied for reuse.
+func (bs *batchSink) flush(batch []logLine) []logLine {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if failed, err := bs.ship(batch); err != nil {
+		atomic.AddUint64(&bs.dropped, uint64(failed))
+		fmt.Fprintf(os.Stderr, "Error while shipping %d log lines to %s :: %s\n", failed, bs.name, err)
+	}
+	return batch[:0]
+}
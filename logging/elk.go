@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// ELKSink ships log lines to elasticsearch through its bulk API. Batches
+// are sent to the configured URLs in turn, moving on to the next one when
+// a URL cannot be reached.
+type ELKSink struct {
+	*batchSink
+
+	URLs       []string
+	Username   string
+	Password   string
+	APIKey     string
+	MaxRetries int
+
+	// index name around its date layout
+	indexPrefix string
+	indexLayout string
+	indexSuffix string
+
+	client *http.Client
+	next   int
+	// wait before the first retry, doubled for each one after it
+	backoff time.Duration
+}
+
+// bulkResponse is the part of the bulk API's response needed to find the
+// log lines that have not been indexed.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// NewELKSink creates an ELKSink indexing into the elasticsearch cluster
+// configured in the log credentials.
+func NewELKSink(conf *config.ELKLogConfig) (*ELKSink, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.TLS.Enabled {
+		tlsConfig, err := tlsClientConfig(&conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	es := &ELKSink{
+		URLs:        conf.URLs,
+		Username:    conf.Username,
+		Password:    conf.Password,
+		APIKey:      conf.APIKey,
+		MaxRetries:  conf.MaxRetries,
+		indexPrefix: conf.Index,
+		client:      &http.Client{Transport: transport, Timeout: conf.TimeoutDuration},
+		backoff:     500 * time.Millisecond,
+	}
+	if start := strings.Index(conf.Index, "{"); start >= 0 {
+		end := strings.Index(conf.Index, "}")
+		es.indexPrefix = conf.Index[:start]
+		es.indexLayout = conf.Index[start+1 : end]
+		es.indexSuffix = conf.Index[end+1:]
+	}
+
+	es.batchSink = newBatchSink("elasticsearch", &conf.LogBatchConfig, es.bulk)
+	return es, nil
+}
+
+// bulk indexes the batch, retrying the lines that failed with a transient
+// error with exponential backoff. Lines rejected by elasticsearch, for
+// instance because they do not match the index mapping, are not retried.
+func (es *ELKSink) bulk(batch []logLine) (int, error) {
+	pending := batch
+	rejected := 0
+	backoff := es.backoff
+
+	for attempt := 0; ; attempt++ {
+		retry, failed, err := es.send(pending)
+		rejected += failed
+		if len(retry) == 0 {
+			if rejected > 0 && err == nil {
+				err = fmt.Errorf("%d log lines rejected", rejected)
+			}
+			return rejected, err
+		}
+
+		if attempt == es.MaxRetries {
+			return rejected + len(retry), err
+		}
+		pending = retry
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send makes a single bulk request. It returns the lines to be retried,
+// the number of lines rejected for good and the error that occurred.
+func (es *ELKSink) send(batch []logLine) ([]logLine, int, error) {
+	var body bytes.Buffer
+	for _, line := range batch {
+		fmt.Fprintf(&body, `{"index":{"_index":%q}}`+"\n", es.index(line.time))
+		body.Write(line.data)
+		body.WriteByte('\n')
+	}
+
+	url := es.URLs[es.next%len(es.URLs)] + "/_bulk"
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return nil, len(batch), err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if es.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+es.APIKey)
+	} else if es.Username != "" {
+		req.SetBasicAuth(es.Username, es.Password)
+	}
+
+	res, err := es.client.Do(req)
+	if err != nil {
+		es.next++
+		return batch, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		io.Copy(ioutil.Discard, res.Body)
+		return batch, 0, fmt.Errorf("%s responded with status %d", url, res.StatusCode)
+	}
+	if res.StatusCode >= 300 {
+		reason, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, len(batch), fmt.Errorf("%s responded with status %d :: %s", url, res.StatusCode, reason)
+	}
+
+	var bulk bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&bulk); err != nil {
+		return nil, 0, fmt.Errorf("error while parsing the bulk response :: %w", err)
+	}
+	if !bulk.Errors {
+		return nil, 0, nil
+	}
+
+	// Items are in the order of the lines in the request
+	var retry []logLine
+	rejected := 0
+	var reason json.RawMessage
+	for i, item := range bulk.Items {
+		for _, result := range item {
+			switch {
+			case i >= len(batch) || result.Status < 300:
+			case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+				retry = append(retry, batch[i])
+			default:
+				rejected++
+				reason = result.Error
+			}
+		}
+	}
+
+	if rejected > 0 {
+		err = fmt.Errorf("%d log lines rejected :: %s", rejected, reason)
+	} else if len(retry) > 0 {
+		err = fmt.Errorf("%d log lines could not be indexed", len(retry))
+	}
+	return retry, rejected, err
+}
+
+// index returns the name of the index a line written at t goes into.
+func (es *ELKSink) index(t time.Time) string {
+	if es.indexLayout == "" {
+		return es.indexPrefix
+	}
+	return es.indexPrefix + t.UTC().Format(es.indexLayout) + es.indexSuffix
+}
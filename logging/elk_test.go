@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// fakeElasticsearch records the bulk requests it receives and answers
+// each of them with the next of its responses, the last one repeatedly.
+type fakeElasticsearch struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	times    []time.Time
+}
+
+// elkResponse is the status and body a bulk request is answered with.
+type elkResponse struct {
+	status int
+	body   string
+}
+
+func newFakeElasticsearch(t *testing.T, responses ...elkResponse) *fakeElasticsearch {
+	f := &fakeElasticsearch{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		f.mu.Lock()
+		response := responses[len(responses)-1]
+		if len(f.requests) < len(responses) {
+			response = responses[len(f.requests)]
+		}
+		f.requests = append(f.requests, string(body))
+		f.times = append(f.times, time.Now())
+		f.mu.Unlock()
+
+		if req.URL.Path != "/_bulk" || req.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		res.WriteHeader(response.status)
+		fmt.Fprint(res, response.body)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Requests returns the bodies of the bulk requests received so far.
+func (f *fakeElasticsearch) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// bulkItems answers a bulk request with an item of each status.
+func bulkItems(statuses ...int) string {
+	items := make([]string, len(statuses))
+	hasErrors := false
+	for i, status := range statuses {
+		items[i] = fmt.Sprintf(`{"index":{"status":%d}}`, status)
+		if status >= 300 {
+			items[i] = fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"error_%d"}}}`, status, status)
+			hasErrors = true
+		}
+	}
+	return fmt.Sprintf(`{"errors":%t,"items":[%s]}`, hasErrors, strings.Join(items, ","))
+}
+
+func newTestELKSink(t *testing.T, index string, maxRetries int, urls ...string) *ELKSink {
+	t.Helper()
+	es, err := NewELKSink(&config.ELKLogConfig{
+		URLs:       urls,
+		Index:      index,
+		MaxRetries: maxRetries,
+		LogBatchConfig: config.LogBatchConfig{
+			BatchSize:             10,
+			BufferSize:            10,
+			FlushIntervalDuration: time.Hour,
+			TimeoutDuration:       2 * time.Second,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	es.backoff = 20 * time.Millisecond
+	return es
+}
+
+func testLines(count int) []logLine {
+	lines := make([]logLine, count)
+	for i := range lines {
+		lines[i] = logLine{data: []byte(fmt.Sprintf(`{"line":%d}`, i)), time: time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)}
+	}
+	return lines
+}
+
+// bulkLines returns the log lines of a bulk request, without the actions.
+func bulkLines(request string) []string {
+	var lines []string
+	for i, line := range strings.Split(strings.TrimRight(request, "\n"), "\n") {
+		if i%2 == 1 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestELKSinkRetriesItems(t *testing.T) {
+	f := newFakeElasticsearch(t,
+		elkResponse{http.StatusOK, bulkItems(201, 429, 400, 503)},
+		elkResponse{http.StatusOK, bulkItems(201, 201)},
+	)
+	es := newTestELKSink(t, "hodor", 3, f.URL)
+
+	failed, err := es.bulk(testLines(4))
+	if failed != 1 || err == nil {
+		t.Errorf("expected the line rejected with a 400 to fail, got %d and %v", failed, err)
+	}
+
+	// Only the lines failing with a transient error are retried
+	requests := f.Requests()
+	want := []string{
+		`{"line":0} {"line":1} {"line":2} {"line":3}`,
+		`{"line":1} {"line":3}`,
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(requests))
+	}
+	for i, request := range requests {
+		if got := strings.Join(bulkLines(request), " "); got != want[i] {
+			t.Errorf("request %d :: expected lines %s, got %s", i, want[i], got)
+		}
+	}
+}
+
+func TestELKSinkRequestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response elkResponse
+		requests int
+		failed   int
+	}{
+		{"too many requests", elkResponse{http.StatusTooManyRequests, ""}, 3, 2},
+		{"server error", elkResponse{http.StatusServiceUnavailable, ""}, 3, 2},
+		{"client error", elkResponse{http.StatusBadRequest, `{"error":"invalid"}`}, 1, 2},
+	}
+
+	for _, test := range tests {
+		f := newFakeElasticsearch(t, test.response)
+		es := newTestELKSink(t, "hodor", 2, f.URL)
+
+		failed, err := es.bulk(testLines(2))
+		if failed != test.failed || err == nil {
+			t.Errorf("%s :: expected %d failed lines and an error, got %d and %v", test.name, test.failed, failed, err)
+		}
+		if requests := len(f.Requests()); requests != test.requests {
+			t.Errorf("%s :: expected %d requests, got %d", test.name, test.requests, requests)
+		}
+	}
+}
+
+func TestELKSinkBackoff(t *testing.T) {
+	f := newFakeElasticsearch(t, elkResponse{http.StatusServiceUnavailable, ""})
+	es := newTestELKSink(t, "hodor", 3, f.URL)
+	es.bulk(testLines(1))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.times) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(f.times))
+	}
+	for i, backoff := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond} {
+		if waited := f.times[i+1].Sub(f.times[i]); waited < backoff {
+			t.Errorf("retry %d :: expected a backoff of at least %s, waited %s", i+1, backoff, waited)
+		}
+	}
+}
+
+func TestELKSinkFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	f := newFakeElasticsearch(t, elkResponse{http.StatusOK, bulkItems(201)})
+	es := newTestELKSink(t, "hodor", 1, down.URL, f.URL)
+
+	for i := 0; i < 2; i++ {
+		if failed, err := es.bulk(testLines(1)); failed != 0 || err != nil {
+			t.Errorf("bulk %d :: expected the lines to be indexed, got %d and %v", i, failed, err)
+		}
+	}
+
+	// The URL that could be reached is kept for the batches after
+	if requests := len(f.Requests()); requests != 2 {
+		t.Errorf("expected 2 requests to the next URL, got %d", requests)
+	}
+}
+
+func TestELKSinkIndex(t *testing.T) {
+	f := newFakeElasticsearch(t, elkResponse{http.StatusOK, bulkItems(201, 201)})
+	es := newTestELKSink(t, "hodor-{2006.01.02}-logs", 0, f.URL)
+
+	// Dates are those of UTC, whatever the zone lines are written in
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	lines := testLines(2)
+	lines[0].time = time.Date(2026, 1, 2, 23, 30, 0, 0, time.UTC)
+	lines[1].time = time.Date(2026, 1, 3, 1, 30, 0, 0, zone)
+
+	if _, err := es.bulk(lines); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"index":{"_index":"hodor-2026.01.02-logs"}}` + "\n" + `{"line":0}` + "\n" +
+		`{"index":{"_index":"hodor-2026.01.02-logs"}}` + "\n" + `{"line":1}` + "\n"
+	if requests := f.Requests(); len(requests) != 1 || requests[0] != want {
+		t.Errorf("expected request\n%s\ngot\n%v", want, requests)
+	}
+}
@@ -0,0 +1,250 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// archiveTimeFormat is used to suffix the names of rotated log files. It
+// sorts lexicographically, which is relied upon to find the oldest archives.
+const archiveTimeFormat = "20060102-150405.000"
+
+// FileSink writes log lines to a file, rotating it by size and by day.
+// Rotated files are renamed with a timestamp suffix and gzipped in the
+// background, only the latest MaxArchives of them are kept.
+type FileSink struct {
+	Path        string
+	MaxSize     int64
+	Daily       bool
+	MaxArchives int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// date the current file has been started on
+	day string
+
+	archives chan string
+}
+
+// NewFileSink opens (or creates) the log file, appending to it if it exists.
+func NewFileSink(conf *config.LogFileConfig) (*FileSink, error) {
+	fs := &FileSink{
+		Path:        conf.Path,
+		MaxSize:     conf.MaxSizeBytes,
+		Daily:       conf.Daily,
+		MaxArchives: conf.MaxArchives,
+		archives:    make(chan string, 16),
+	}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+
+	go fs.compress()
+	return fs, nil
+}
+
+// Write implements zapcore.WriteSyncer. The file is rotated before writing
+// if the line would take it beyond its max size or a new day has begun.
+func (fs *FileSink) Write(p []byte) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		if err := fs.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if fs.size > 0 && (fs.size+int64(len(p)) > fs.MaxSize || fs.Daily && today() != fs.day) {
+		if err := fs.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := fs.file.Write(p)
+	fs.size += int64(n)
+	return n, err
+}
+
+// Sync implements zapcore.WriteSyncer.
+func (fs *FileSink) Sync() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		return nil
+	}
+	return fs.file.Sync()
+}
+
+// Reopen closes the log file and opens the file at its path again. It lets
+// external tools like logrotate move the file away and have the gateway
+// start a new one.
+func (fs *FileSink) Reopen() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.close()
+	return fs.open()
+}
+
+func (fs *FileSink) open() error {
+	file, err := os.OpenFile(fs.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	fs.file = file
+	fs.size = info.Size()
+	fs.day = today()
+	if fs.size > 0 {
+		fs.day = info.ModTime().Format("2006-01-02")
+	}
+	return nil
+}
+
+func (fs *FileSink) close() {
+	if fs.file != nil {
+		fs.file.Close()
+		fs.file = nil
+	}
+}
+
+// rotate moves the current file aside, queues it for compression and
+// starts a new file.
+func (fs *FileSink) rotate() error {
+	fs.close()
+
+	archive := fs.archivePath(time.Now())
+	if err := os.Rename(fs.Path, archive); err != nil {
+		return err
+	}
+
+	select {
+	case fs.archives <- archive:
+	default:
+		// The compressor is lagging behind, leave the archive as is
+		// rather than blocking the logger.
+	}
+
+	return fs.open()
+}
+
+// archivePath returns the path to move the current file to, stamped with
+// the time of the rotation. A rotation within the same millisecond as the
+// previous one is stamped a millisecond later, so that it does not replace
+// the previous archive and archives still sort by age.
+func (fs *FileSink) archivePath(now time.Time) string {
+	for {
+		archive := fs.Path + "." + now.Format(archiveTimeFormat)
+		// The archive may be being compressed, gzipFile creates the
+		// compressed file before removing the original one
+		if !fileExists(archive) && !fileExists(archive+".gz") {
+			return archive
+		}
+		now = now.Add(time.Millisecond)
+	}
+}
+
+// compress gzips the rotated files queued up by rotate and removes the
+// archives beyond MaxArchives, oldest first.
+func (fs *FileSink) compress() {
+	for archive := range fs.archives {
+		if err := gzipFile(archive); err != nil {
+			fmt.Fprintf(os.Stderr, "Error while compressing log file '%s' :: %s\n", archive, err)
+		}
+
+		fs.removeOldArchives()
+	}
+}
+
+// removeOldArchives removes the archives beyond MaxArchives, oldest first.
+// Only files named the way rotate names them count as archives, other
+// files sharing the name of the log file as a prefix are left alone.
+func (fs *FileSink) removeOldArchives() {
+	dir, base := filepath.Split(fs.Path)
+	entries, err := ioutil.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while listing log file archives :: %s\n", err)
+		return
+	}
+
+	var archives []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".gz")
+		if _, err := time.Parse(archiveTimeFormat, stamp); err != nil {
+			continue
+		}
+		archives = append(archives, filepath.Join(dir, name))
+	}
+
+	sort.Strings(archives)
+	for len(archives) > fs.MaxArchives {
+		os.Remove(archives[0])
+		archives = archives[1:]
+	}
+}
+
+// gzipFile replaces a file with its gzipped version, named with a '.gz'
+// suffix.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+func TestFileSinkRemovesOldArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hodor-file-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"hodor.log.20260101-000000.000.gz",
+		"hodor.log.20260102-000000.000.gz",
+		"hodor.log.20260103-000000.000",
+		// Files the sink has not created are never removed
+		"hodor.log.1",
+		"hodor.log.bak",
+		"hodor.log.old.gz",
+		"hodor.log.20260101.gz",
+		"other.log.20260101-000000.000.gz",
+	}
+	for _, name := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("line\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs, err := NewFileSink(&config.LogFileConfig{Path: filepath.Join(dir, "hodor.log"), MaxSizeBytes: 8, MaxArchives: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.Write([]byte("first line\n"))
+	fs.Write([]byte("second line\n"))
+
+	// The rotated file is compressed in the background
+	want := []string{
+		"hodor.log",
+		"hodor.log.1",
+		"hodor.log.20260101.gz",
+		"hodor.log.20260103-000000.000",
+		"hodor.log.bak",
+		"hodor.log.old.gz",
+		"other.log.20260101-000000.000.gz",
+	}
+	var got []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		got = listFiles(t, dir)
+		if len(got) == len(want)+1 {
+			break
+		}
+	}
+
+	if len(got) != len(want)+1 {
+		t.Fatalf("expected %d files, got %v", len(want)+1, got)
+	}
+	rotated := got[4]
+	if !strings.HasPrefix(rotated, "hodor.log.2") || !strings.HasSuffix(rotated, ".gz") || rotated <= "hodor.log.20260103" {
+		t.Errorf("expected the rotated file to be archived, got '%s'", rotated)
+	}
+	got = append(got[:4], got[5:]...)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected files %v, got %v", want, got)
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileSinkArchivePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hodor-file-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	// Archives of earlier rotations within the same milliseconds,
+	// one of them compressed already
+	for _, name := range []string{"hodor.log.20260101-120000.000", "hodor.log.20260101-120000.001.gz"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("line\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := &FileSink{Path: filepath.Join(dir, "hodor.log")}
+	want := filepath.Join(dir, "hodor.log.20260101-120000.002")
+	if got := fs.archivePath(now); got != want {
+		t.Errorf("expected archive path '%s', got '%s'", want, got)
+	}
+	want = filepath.Join(dir, "hodor.log.20260101-120001.000")
+	if got := fs.archivePath(now.Add(time.Second)); got != want {
+		t.Errorf("expected archive path '%s', got '%s'", want, got)
+	}
+}
+
+func TestFileSinkRapidRotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hodor-file-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := NewFileSink(&config.LogFileConfig{Path: filepath.Join(dir, "hodor.log"), MaxSizeBytes: 1, MaxArchives: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every line but the first rotates the file, most of them
+	// within the same millisecond
+	for i := 0; i < 10; i++ {
+		fs.Write([]byte("line\n"))
+	}
+
+	// An archive may exist both compressed and not while it is compressed
+	var archives map[string]bool
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		archives = make(map[string]bool)
+		for _, name := range listFiles(t, dir) {
+			if name != "hodor.log" {
+				archives[strings.TrimSuffix(name, ".gz")] = true
+			}
+		}
+		if len(archives) == 9 {
+			break
+		}
+	}
+	if len(archives) != 9 {
+		t.Errorf("expected 9 archives, got %v", archives)
+	}
+}
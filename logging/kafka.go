@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"crypto/tls"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// KafkaSink ships log lines to a kafka topic, spreading the batches across
+// the partitions of the topic.
+type KafkaSink struct {
+	*batchSink
+
+	client *kafkaClient
+}
+
+// NewKafkaSink creates a KafkaSink producing to the topic configured in
+// the log credentials. Brokers are only connected to once the first batch
+// is produced.
+func NewKafkaSink(conf *config.KafkaLogConfig) (*KafkaSink, error) {
+	var tlsConfig *tls.Config
+	if conf.TLS.Enabled {
+		var err error
+		if tlsConfig, err = tlsClientConfig(&conf.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	ks := &KafkaSink{client: newKafkaClient(conf, tlsConfig)}
+	ks.batchSink = newBatchSink("kafka", &conf.LogBatchConfig, ks.produce)
+	return ks, nil
+}
+
+func (ks *KafkaSink) produce(batch []logLine) (int, error) {
+	messages := make([][]byte, len(batch))
+	for i, line := range batch {
+		messages[i] = line.data
+	}
+
+	if err := ks.client.produce(messages); err != nil {
+		return len(batch), err
+	}
+	return 0, nil
+}
@@ -0,0 +1,623 @@
+package logging
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// kafkaBatch is a record batch received by fakeKafka.
+type kafkaBatch struct {
+	partition int32
+	messages  []string
+}
+
+// fakeKafka is an in-process kafka broker. It leads both partitions of
+// every topic, decodes the record batches it is sent and, if a mechanism
+// is set, requires clients to authenticate with SCRAM as one of its users.
+type fakeKafka struct {
+	listener  net.Listener
+	mechanism string
+	users     map[string]string
+
+	// produced is signalled on every produce request, which is then
+	// held until release is closed, if set
+	produced chan struct{}
+	release  chan struct{}
+
+	mu      sync.Mutex
+	batches []kafkaBatch
+}
+
+func newFakeKafka(t *testing.T) *fakeKafka {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen :: %s", err)
+	}
+
+	f := &fakeKafka{listener: listener, produced: make(chan struct{}, 100)}
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+// start accepts connections, once the fake has been configured.
+func (f *fakeKafka) start() *fakeKafka {
+	go func() {
+		for {
+			conn, err := f.listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return f
+}
+
+// Batches returns the record batches received so far.
+func (f *fakeKafka) Batches() []kafkaBatch {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kafkaBatch(nil), f.batches...)
+}
+
+func (f *fakeKafka) conf(batchSize, bufferSize int) *config.KafkaLogConfig {
+	return &config.KafkaLogConfig{
+		Brokers:  []string{f.listener.Addr().String()},
+		Topic:    "logs",
+		ClientID: "hodor-test",
+		Acks:     config.KafkaAcksLeader,
+		LogBatchConfig: config.LogBatchConfig{
+			BatchSize:             batchSize,
+			BufferSize:            bufferSize,
+			FlushIntervalDuration: time.Hour,
+			TimeoutDuration:       2 * time.Second,
+		},
+	}
+}
+
+// scramSession is the state of a SCRAM exchange on a connection.
+type scramSession struct {
+	hash            func() hash.Hash
+	password        string
+	clientFirstBare string
+	serverFirst     string
+}
+
+func (f *fakeKafka) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	var session *scramSession
+	authenticated := f.mechanism == ""
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(reader, request); err != nil {
+			return
+		}
+
+		d := &kafkaDecoder{buf: request}
+		apiKey, version, correlationID := d.int16(), d.int16(), d.int32()
+		d.string() // client id
+
+		var response *kafkaEncoder
+		switch {
+		case apiKey == kafkaAPISaslHandshake && version == kafkaVersionSaslHandshake:
+			response = &kafkaEncoder{}
+			if d.string() != f.mechanism {
+				response.int16(33) // UNSUPPORTED_SASL_MECHANISM
+			} else {
+				response.int16(0)
+			}
+			response.int32(1)
+			response.string(f.mechanism)
+
+		case apiKey == kafkaAPISaslAuthenticate && version == kafkaVersionSaslAuthenticate:
+			var err error
+			var reply []byte
+			if session == nil {
+				session, reply, err = f.scramFirst(string(d.bytes()))
+			} else {
+				reply, err = session.final(string(d.bytes()))
+				authenticated = err == nil
+			}
+
+			response = &kafkaEncoder{}
+			if err != nil {
+				response.int16(58) // SASL_AUTHENTICATION_FAILED
+				response.string(err.Error())
+			} else {
+				response.int16(0)
+				response.int16(-1)
+			}
+			response.bytes(reply)
+
+		case !authenticated:
+			return
+
+		case apiKey == kafkaAPIMetadata && version == kafkaVersionMetadata:
+			d.int32()
+			response = f.metadata(d.string())
+
+		case apiKey == kafkaAPIProduce && version == kafkaVersionProduce:
+			if response = f.produce(d); response == nil {
+				continue
+			}
+
+		default:
+			return
+		}
+
+		frame := kafkaEncoder{}
+		frame.int32(int32(4 + len(response.buf)))
+		frame.int32(correlationID)
+		frame.buf = append(frame.buf, response.buf...)
+		if _, err := conn.Write(frame.buf); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeKafka) metadata(topic string) *kafkaEncoder {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	e := &kafkaEncoder{}
+	e.int32(0) // throttle time
+	e.int32(1)
+	e.int32(1)
+	e.string(host)
+	e.int32(int32(portNumber))
+	e.int16(-1) // null rack
+	e.int16(-1) // null cluster id
+	e.int32(1)  // controller id
+
+	e.int32(1)
+	e.int16(0)
+	e.string(topic)
+	e.int8(0) // not internal
+	e.int32(2)
+	for partition := int32(0); partition < 2; partition++ {
+		e.int16(0)
+		e.int32(partition)
+		e.int32(1) // leader
+		e.int32(1) // replicas
+		e.int32(1)
+		e.int32(1) // in sync replicas
+		e.int32(1)
+	}
+	return e
+}
+
+// produce records the batches of a produce request and returns the
+// response to it, or nil if no acknowledgement has been requested.
+func (f *fakeKafka) produce(d *kafkaDecoder) *kafkaEncoder {
+	d.string() // transactional id
+	acks := d.int16()
+	d.int32() // timeout
+	d.int32() // a single topic
+	topic := d.string()
+	d.int32() // a single partition
+	partition := d.int32()
+	messages, err := decodeRecordBatch(d.bytes())
+
+	f.produced <- struct{}{}
+	if f.release != nil {
+		<-f.release
+	}
+
+	code := int16(0)
+	if err != nil || d.err != nil {
+		code = 2 // CORRUPT_MESSAGE
+	} else {
+		f.mu.Lock()
+		f.batches = append(f.batches, kafkaBatch{partition: partition, messages: messages})
+		f.mu.Unlock()
+	}
+
+	if acks == 0 {
+		return nil
+	}
+	e := &kafkaEncoder{}
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.int16(code)
+	e.int64(0)  // base offset
+	e.int64(-1) // log append time
+	e.int32(0)  // throttle time
+	return e
+}
+
+// decodeRecordBatch decodes the values of the records of a batch of the
+// message format v2, checking its length and CRC.
+func decodeRecordBatch(batch []byte) ([]string, error) {
+	d := &kafkaDecoder{buf: batch}
+	d.int64() // base offset
+	length := d.int32()
+	d.int32() // partition leader epoch
+	magic := d.next(1)[0]
+	crc := uint32(d.int32())
+	if d.err != nil || magic != 2 || int(length) != 4+1+4+len(d.buf) {
+		return nil, errors.New("invalid record batch header")
+	}
+	if crc32.Checksum(d.buf, crc32c) != crc {
+		return nil, errors.New("invalid record batch CRC")
+	}
+
+	d.int16() // attributes
+	lastOffsetDelta := d.int32()
+	d.int64() // first timestamp
+	d.int64() // max timestamp
+	d.int64() // producer id
+	d.int16() // producer epoch
+	d.int32() // base sequence
+	count := d.int32()
+	if count != lastOffsetDelta+1 {
+		return nil, errors.New("invalid record count")
+	}
+
+	messages := make([]string, count)
+	for i := range messages {
+		record := &kafkaDecoder{buf: d.next(int(varint(d)))}
+		record.next(1) // attributes
+		varint(record) // timestamp delta
+		if varint(record) != int64(i) || varint(record) != -1 {
+			return nil, errors.New("invalid record offset or key")
+		}
+		messages[i] = string(record.next(int(varint(record))))
+		varint(record) // headers
+		if record.err != nil || len(record.buf) != 0 {
+			return nil, errors.New("invalid record")
+		}
+	}
+	if d.err != nil || len(d.buf) != 0 {
+		return nil, errors.New("invalid records")
+	}
+	return messages, nil
+}
+
+func varint(d *kafkaDecoder) int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// scramFirst answers the client's first SCRAM message.
+func (f *fakeKafka) scramFirst(clientFirst string) (*scramSession, []byte, error) {
+	session := &scramSession{hash: sha512.New}
+	if f.mechanism == config.KafkaSASLScramSHA256 {
+		session.hash = sha256.New
+	}
+
+	session.clientFirstBare = strings.TrimPrefix(clientFirst, "n,,")
+	attrs := scramAttributes(session.clientFirstBare)
+	username := strings.NewReplacer("=2C", ",", "=3D", "=").Replace(attrs["n"])
+	password, ok := f.users[username]
+	if !ok || attrs["r"] == "" {
+		return nil, nil, errors.New("unknown user")
+	}
+	session.password = password
+
+	session.serverFirst = "r=" + attrs["r"] + "fake-server-nonce,s=" + base64.StdEncoding.EncodeToString([]byte("fake-salt")) + ",i=4096"
+	return session, []byte(session.serverFirst), nil
+}
+
+// final checks the proof of the client's final SCRAM message and answers
+// with the server signature.
+func (s *scramSession) final(clientFinal string) ([]byte, error) {
+	i := strings.Index(clientFinal, ",p=")
+	proof, err := base64.StdEncoding.DecodeString(scramAttributes(clientFinal)["p"])
+	if i < 0 || err != nil || !strings.HasPrefix(clientFinal, "c=biws,r="+scramAttributes(s.serverFirst)["r"]+",") {
+		return nil, errors.New("invalid client final message")
+	}
+
+	mac := func(key []byte, message string) []byte {
+		m := hmac.New(s.hash, key)
+		m.Write([]byte(message))
+		return m.Sum(nil)
+	}
+
+	salted := pbkdf2(s.hash, []byte(s.password), []byte("fake-salt"), 4096)
+	storedKey := s.hash()
+	storedKey.Write(mac(salted, "Client Key"))
+	authMessage := s.clientFirstBare + "," + s.serverFirst + "," + clientFinal[:i]
+
+	// The client key recovered from the proof must hash to the stored key
+	clientKey := mac(storedKey.Sum(nil), authMessage)
+	if len(proof) != len(clientKey) {
+		return nil, errors.New("invalid proof")
+	}
+	for j := range clientKey {
+		clientKey[j] ^= proof[j]
+	}
+	recovered := s.hash()
+	recovered.Write(clientKey)
+	if !hmac.Equal(recovered.Sum(nil), storedKey.Sum(nil)) {
+		return nil, errors.New("invalid proof")
+	}
+
+	return []byte("v=" + base64.StdEncoding.EncodeToString(mac(mac(salted, "Server Key"), authMessage))), nil
+}
+
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(message, ",") {
+		if len(attr) > 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+	return attrs
+}
+
+func TestKafkaSinkBatches(t *testing.T) {
+	f := newFakeKafka(t).start()
+	ks, err := NewKafkaSink(f.conf(3, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 7; i++ {
+		fmt.Fprintf(ks, "{\"line\":%d}\n", i)
+	}
+	if err := ks.Sync(); err != nil {
+		t.Fatalf("unexpected error :: %s", err)
+	}
+
+	// Full batches are produced as soon as they fill up, the rest on
+	// sync, spread across the partitions
+	want := []kafkaBatch{
+		{0, []string{`{"line":0}`, `{"line":1}`, `{"line":2}`}},
+		{1, []string{`{"line":3}`, `{"line":4}`, `{"line":5}`}},
+		{0, []string{`{"line":6}`}},
+	}
+	if got := f.Batches(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected batches %v, got %v", want, got)
+	}
+	if dropped := ks.Dropped(); dropped != 0 {
+		t.Errorf("expected no dropped lines, got %d", dropped)
+	}
+}
+
+func TestKafkaSinkDropsWhenBufferFull(t *testing.T) {
+	f := newFakeKafka(t)
+	f.release = make(chan struct{})
+	f.start()
+
+	ks, err := NewKafkaSink(f.conf(1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first line is held up by the broker, two more fit in the
+	// buffer and the rest are dropped rather than blocking
+	fmt.Fprintln(ks, "line 0")
+	select {
+	case <-f.produced:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the first line to be produced")
+	}
+	for i := 1; i < 6; i++ {
+		fmt.Fprintf(ks, "line %d\n", i)
+	}
+	if dropped := ks.Dropped(); dropped != 3 {
+		t.Errorf("expected 3 dropped lines, got %d", dropped)
+	}
+
+	close(f.release)
+	if err := ks.Sync(); err != nil {
+		t.Fatalf("unexpected error :: %s", err)
+	}
+
+	var produced []string
+	for _, batch := range f.Batches() {
+		produced = append(produced, batch.messages...)
+	}
+	if want := "line 0,line 1,line 2"; strings.Join(produced, ",") != want {
+		t.Errorf("expected lines %s to be produced, got %v", want, produced)
+	}
+}
+
+func TestKafkaSCRAM(t *testing.T) {
+	tests := []struct {
+		mechanism string
+		password  string
+		ok        bool
+	}{
+		{config.KafkaSASLScramSHA256, "pencil", true},
+		{config.KafkaSASLScramSHA512, "pencil", true},
+		{config.KafkaSASLScramSHA512, "crayon", false},
+	}
+
+	for _, test := range tests {
+		f := newFakeKafka(t)
+		f.mechanism = test.mechanism
+		f.users = map[string]string{"user,1": "pencil"}
+		f.start()
+
+		conf := f.conf(1, 1)
+		conf.SASL = config.KafkaSASLConfig{Mechanism: test.mechanism, Username: "user,1", Password: test.password}
+		err := newKafkaClient(conf, nil).produce([][]byte{[]byte("line")})
+
+		if test.ok && err != nil {
+			t.Errorf("%s :: unexpected error :: %s", test.mechanism, err)
+		}
+		if !test.ok && (err == nil || !strings.Contains(err.Error(), "SASL authentication failed")) {
+			t.Errorf("%s :: expected authentication to fail, got %v", test.mechanism, err)
+		}
+		if produced := len(f.Batches()); test.ok != (produced == 1) {
+			t.Errorf("%s :: unexpected number of batches produced %d", test.mechanism, produced)
+		}
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors of RFC 6070
+	tests := []struct {
+		iterations int
+		key        string
+	}{
+		{1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{4096, "4b007901b765489abead49d926f721d065a429c1"},
+	}
+
+	for _, test := range tests {
+		key := hex.EncodeToString(pbkdf2(sha1.New, []byte("password"), []byte("salt"), test.iterations))
+		if key != test.key {
+			t.Errorf("%d iterations :: expected %s, got %s", test.iterations, test.key, key)
+		}
+	}
+}
+
+func TestRecordBatchFraming(t *testing.T) {
+	// Check value of CRC-32C, as given in RFC 3720
+	if crc := crc32.Checksum([]byte("123456789"), crc32c); crc != 0xe3069283 {
+		t.Errorf("expected CRC-32C check value e3069283, got %08x", crc)
+	}
+
+	// Record batch of the message format v2 as laid out in the kafka
+	// protocol documentation, with the CRC-32C of the bytes following it
+	want := "0000000000000000" + // base offset
+		"00000042" + // batch length
+		"ffffffff" + // partition leader epoch
+		"02" + // magic
+		"98411288" + // CRC
+		"0000" + // attributes
+		"00000001" + // last offset delta
+		"00000174876e8000" + // first timestamp
+		"00000174876e8000" + // max timestamp
+		"ffffffffffffffff" + // producer id
+		"ffff" + // producer epoch
+		"ffffffff" + // base sequence
+		"00000002" + // records count
+		"0e" + "00" + "00" + "00" + "01" + "02" + "61" + "00" + // length, attributes, timestamp delta, offset delta, null key, value length, "a", headers
+		"10" + "00" + "00" + "02" + "01" + "04" + "6263" + "00" // the same for "bc", at offset delta 1
+
+	batch := recordBatch([][]byte{[]byte("a"), []byte("bc")}, time.Unix(1600000000, 0))
+	if got := hex.EncodeToString(batch); got != want {
+		t.Errorf("expected record batch\n%s\ngot\n%s", want, got)
+	}
+}
+
+// kafkaExchange is a request the client is expected to send, byte for
+// byte, and the response the broker answers it with.
+type kafkaExchange struct {
+	request  string
+	response string
+}
+
+// scriptedKafka returns a connection to a broker expecting the exchanges
+// in order. It closes the connection on an unexpected request.
+func scriptedKafka(t *testing.T, exchanges []kafkaExchange) *kafkaConn {
+	client, broker := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	go func() {
+		defer broker.Close()
+		for i, exchange := range exchanges {
+			request := make([]byte, len(exchange.request))
+			if _, err := io.ReadFull(broker, request); err != nil {
+				t.Errorf("exchange %d :: failed to read the request :: %s", i, err)
+				return
+			}
+			if string(request) != exchange.request {
+				t.Errorf("exchange %d :: expected request %q, got %q", i, exchange.request, request)
+				return
+			}
+			if _, err := broker.Write([]byte(exchange.response)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &kafkaConn{conn: client, reader: bufio.NewReader(client), clientID: "hodor", timeout: 2 * time.Second}
+}
+
+func TestKafkaSASLPlainFraming(t *testing.T) {
+	// The frames are made of the size, the request header (API key,
+	// version, correlation id and client id) and the request body. The
+	// PLAIN message is defined by RFC 4616.
+	c := scriptedKafka(t, []kafkaExchange{
+		{
+			"\x00\x00\x00\x16" + "\x00\x11\x00\x01\x00\x00\x00\x01" + "\x00\x05hodor" + "\x00\x05PLAIN",
+			"\x00\x00\x00\x11" + "\x00\x00\x00\x01" + "\x00\x00" + "\x00\x00\x00\x01\x00\x05PLAIN",
+		},
+		{
+			"\x00\x00\x00\x1f" + "\x00\x24\x00\x00\x00\x00\x00\x02" + "\x00\x05hodor" + "\x00\x00\x00\x0c\x00user\x00pencil",
+			"\x00\x00\x00\x0c" + "\x00\x00\x00\x02" + "\x00\x00" + "\xff\xff" + "\x00\x00\x00\x00",
+		},
+	})
+
+	err := c.authenticate(&config.KafkaSASLConfig{Mechanism: config.KafkaSASLPlain, Username: "user", Password: "pencil"})
+	if err != nil {
+		t.Errorf("unexpected error :: %s", err)
+	}
+}
+
+func TestKafkaSCRAMExchange(t *testing.T) {
+	// Example exchange of RFC 7677 for SCRAM-SHA-256
+	clientFirst := "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"
+	serverFirst := "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	clientFinal := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	serverFinal := "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+
+	tests := []struct {
+		name        string
+		password    string
+		serverFinal string
+		ok          bool
+	}{
+		{"valid", "pencil", serverFinal, true},
+		{"invalid server signature", "pencil", "v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", false},
+	}
+
+	for _, test := range tests {
+		c := scriptedKafka(t, []kafkaExchange{
+			{
+				"\x00\x00\x00\x33" + "\x00\x24\x00\x00\x00\x00\x00\x01" + "\x00\x05hodor" + "\x00\x00\x00\x20" + clientFirst,
+				"\x00\x00\x00\x62" + "\x00\x00\x00\x01" + "\x00\x00" + "\xff\xff" + "\x00\x00\x00\x56" + serverFirst,
+			},
+			{
+				"\x00\x00\x00\x7d" + "\x00\x24\x00\x00\x00\x00\x00\x02" + "\x00\x05hodor" + "\x00\x00\x00\x6a" + clientFinal,
+				"\x00\x00\x00\x3a" + "\x00\x00\x00\x02" + "\x00\x00" + "\xff\xff" + "\x00\x00\x00\x2e" + test.serverFinal,
+			},
+		})
+
+		err := c.scramExchange(sha256.New, "user", test.password, "rOprNGfwEbeRWgbNEkqO")
+		if test.ok && err != nil {
+			t.Errorf("%s :: unexpected error :: %s", test.name, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s :: expected the exchange to fail", test.name)
+		}
+	}
+}
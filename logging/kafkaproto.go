@@ -0,0 +1,571 @@
+package logging
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// Kafka API keys and the versions of them spoken by the client. They are
+// the oldest versions still supported by current brokers.
+const (
+	kafkaAPIProduce          = 0
+	kafkaAPIMetadata         = 3
+	kafkaAPISaslHandshake    = 17
+	kafkaAPISaslAuthenticate = 36
+
+	kafkaVersionProduce          = 3
+	kafkaVersionMetadata         = 4
+	kafkaVersionSaslHandshake    = 1
+	kafkaVersionSaslAuthenticate = 0
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaError is an error code returned by a kafka broker.
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+// kafkaEncoder appends the primitive types of the kafka protocol to a buffer.
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	e.buf = append(e.buf, byte(v>>8), byte(v))
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	e.int32(int32(v >> 32))
+	e.int32(int32(v))
+}
+
+func (e *kafkaEncoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads the primitive types of the kafka protocol from a
+// buffer. Reading beyond the end of the buffer sets err and returns zero
+// values, so that it only has to be checked once done.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err == nil && n <= len(d.buf) {
+		b := d.buf[:n]
+		d.buf = d.buf[n:]
+		return b
+	}
+
+	d.err = io.ErrUnexpectedEOF
+	if n > 8 {
+		return nil
+	}
+	return make([]byte, n)
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.next(2)))
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.next(4)))
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.next(8)))
+}
+
+func (d *kafkaDecoder) bool() bool {
+	return d.next(1)[0] != 0
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// kafkaConn is a single connection to a kafka broker.
+type kafkaConn struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+}
+
+// request sends a request to the broker and returns the body of its
+// response. Produce requests with no acks required get no response.
+func (c *kafkaConn) request(apiKey, apiVersion int16, body []byte, hasResponse bool) ([]byte, error) {
+	c.correlationID++
+
+	e := kafkaEncoder{buf: make([]byte, 4, 4+10+len(c.clientID)+len(body))}
+	e.int16(apiKey)
+	e.int16(apiVersion)
+	e.int32(c.correlationID)
+	e.string(c.clientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(e.buf); err != nil {
+		return nil, err
+	}
+	if !hasResponse {
+		return nil, nil
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	if size < 4 || size > 64<<20 {
+		return nil, fmt.Errorf("invalid kafka response size %d", size)
+	}
+	if correlationID := int32(binary.BigEndian.Uint32(header[4:])); correlationID != c.correlationID {
+		return nil, fmt.Errorf("kafka response for request %d received while expecting %d", correlationID, c.correlationID)
+	}
+
+	response := make([]byte, size-4)
+	if _, err := io.ReadFull(c.reader, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// authenticate performs the SASL handshake and exchange with the broker.
+func (c *kafkaConn) authenticate(sasl *config.KafkaSASLConfig) error {
+	e := kafkaEncoder{}
+	e.string(sasl.Mechanism)
+	response, err := c.request(kafkaAPISaslHandshake, kafkaVersionSaslHandshake, e.buf, true)
+	if err != nil {
+		return err
+	}
+	d := kafkaDecoder{buf: response}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("SASL mechanism %s not enabled on the broker: %w", sasl.Mechanism, kafkaError(code))
+	}
+
+	switch sasl.Mechanism {
+	case config.KafkaSASLPlain:
+		_, err := c.saslAuthenticate([]byte("\x00" + sasl.Username + "\x00" + sasl.Password))
+		return err
+
+	case config.KafkaSASLScramSHA256:
+		return c.scram(sha256.New, sasl.Username, sasl.Password)
+
+	default:
+		return c.scram(sha512.New, sasl.Username, sasl.Password)
+	}
+}
+
+func (c *kafkaConn) saslAuthenticate(payload []byte) ([]byte, error) {
+	e := kafkaEncoder{}
+	e.bytes(payload)
+	response, err := c.request(kafkaAPISaslAuthenticate, kafkaVersionSaslAuthenticate, e.buf, true)
+	if err != nil {
+		return nil, err
+	}
+
+	d := kafkaDecoder{buf: response}
+	code := d.int16()
+	message := d.string()
+	reply := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("SASL authentication failed: %s: %w", message, kafkaError(code))
+	}
+	return reply, nil
+}
+
+// scram performs a SCRAM exchange as described in RFC 5802.
+func (c *kafkaConn) scram(h func() hash.Hash, username, password string) error {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return c.scramExchange(h, username, password, base64.RawStdEncoding.EncodeToString(nonce))
+}
+
+// scramExchange performs a SCRAM exchange with the given client nonce.
+func (c *kafkaConn) scramExchange(h func() hash.Hash, username, password, clientNonce string) error {
+	username = strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+	clientFirst := "n=" + username + ",r=" + clientNonce
+	serverFirst, err := c.saslAuthenticate([]byte("n,," + clientFirst))
+	if err != nil {
+		return err
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(string(serverFirst), ",") {
+		if len(attr) > 2 && attr[1] == '=' {
+			attrs[attr[:1]] = attr[2:]
+		}
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	iterations, _ := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 || !strings.HasPrefix(attrs["r"], clientNonce) {
+		return errors.New("invalid SCRAM server challenge")
+	}
+
+	mac := func(key []byte, message string) []byte {
+		m := hmac.New(h, key)
+		m.Write([]byte(message))
+		return m.Sum(nil)
+	}
+
+	salted := pbkdf2(h, []byte(password), salt, iterations)
+	clientKey := mac(salted, "Client Key")
+	storedKey := h()
+	storedKey.Write(clientKey)
+
+	clientFinal := "c=biws,r=" + attrs["r"]
+	authMessage := clientFirst + "," + string(serverFirst) + "," + clientFinal
+	proof := mac(storedKey.Sum(nil), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+
+	serverFinal, err := c.saslAuthenticate([]byte(clientFinal + ",p=" + base64.StdEncoding.EncodeToString(proof)))
+	if err != nil {
+		return err
+	}
+
+	signature := base64.StdEncoding.EncodeToString(mac(mac(salted, "Server Key"), authMessage))
+	if string(serverFinal) != "v="+signature {
+		return errors.New("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+// pbkdf2 derives a key as described in RFC 8018, with the length of the
+// hash function's output.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	prf := hmac.New(h, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// kafkaPartition is a partition of the topic and the broker leading it.
+type kafkaPartition struct {
+	index  int32
+	leader int32
+}
+
+// kafkaClient is a minimal kafka producer for a single topic. It looks the
+// partitions of the topic up and spreads batches across them round robin.
+// It is not safe for concurrent use.
+type kafkaClient struct {
+	conf *config.KafkaLogConfig
+	tls  *tls.Config
+	acks int16
+
+	brokers    map[int32]string
+	partitions []kafkaPartition
+	conns      map[int32]*kafkaConn
+	next       int
+}
+
+func newKafkaClient(conf *config.KafkaLogConfig, tlsConfig *tls.Config) *kafkaClient {
+	acks := int16(1)
+	switch conf.Acks {
+	case config.KafkaAcksNone:
+		acks = 0
+	case config.KafkaAcksAll:
+		acks = -1
+	}
+
+	return &kafkaClient{
+		conf:  conf,
+		tls:   tlsConfig,
+		acks:  acks,
+		conns: make(map[int32]*kafkaConn),
+	}
+}
+
+// produce sends the messages to the next partition of the topic as a
+// single record batch. The partitions are looked up again and the batch
+// retried once if producing fails.
+func (c *kafkaClient) produce(messages [][]byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = c.tryProduce(messages); err == nil {
+			return nil
+		}
+		c.reset()
+	}
+	return err
+}
+
+func (c *kafkaClient) tryProduce(messages [][]byte) error {
+	if len(c.partitions) == 0 {
+		if err := c.refreshMetadata(); err != nil {
+			return err
+		}
+	}
+
+	partition := c.partitions[c.next%len(c.partitions)]
+	c.next++
+
+	conn, ok := c.conns[partition.leader]
+	if !ok {
+		var err error
+		if conn, err = c.dial(c.brokers[partition.leader]); err != nil {
+			return err
+		}
+		c.conns[partition.leader] = conn
+	}
+
+	e := kafkaEncoder{}
+	e.int16(-1) // null transactional id
+	e.int16(c.acks)
+	e.int32(int32(c.conf.TimeoutDuration / time.Millisecond))
+	e.int32(1)
+	e.string(c.conf.Topic)
+	e.int32(1)
+	e.int32(partition.index)
+	e.bytes(recordBatch(messages, time.Now()))
+
+	response, err := conn.request(kafkaAPIProduce, kafkaVersionProduce, e.buf, c.acks != 0)
+	if err != nil || c.acks == 0 {
+		return err
+	}
+
+	d := kafkaDecoder{buf: response}
+	for topics := d.int32(); topics > 0; topics-- {
+		d.string()
+		for partitions := d.int32(); partitions > 0; partitions-- {
+			d.int32()
+			if code := d.int16(); code != 0 && d.err == nil {
+				return kafkaError(code)
+			}
+			d.int64()
+			d.int64()
+		}
+	}
+	return d.err
+}
+
+// refreshMetadata looks the brokers and the partitions of the topic up,
+// asking the bootstrap brokers in turn.
+func (c *kafkaClient) refreshMetadata() error {
+	e := kafkaEncoder{}
+	e.int32(1)
+	e.string(c.conf.Topic)
+	e.int8(0) // do not auto create the topic
+
+	var err error
+	for _, address := range c.conf.Brokers {
+		var conn *kafkaConn
+		if conn, err = c.dial(address); err != nil {
+			continue
+		}
+
+		var response []byte
+		response, err = conn.request(kafkaAPIMetadata, kafkaVersionMetadata, e.buf, true)
+		conn.conn.Close()
+		if err != nil {
+			continue
+		}
+		if err = c.parseMetadata(response); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("error while fetching kafka metadata: %w", err)
+}
+
+func (c *kafkaClient) parseMetadata(response []byte) error {
+	d := kafkaDecoder{buf: response}
+	d.int32() // throttle time
+
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster id
+	d.int32()  // controller id
+
+	var partitions []kafkaPartition
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		if code := d.int16(); code != 0 {
+			return fmt.Errorf("topic '%s': %w", c.conf.Topic, kafkaError(code))
+		}
+		d.string()
+		d.bool()
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int16()
+			index := d.int32()
+			leader := d.int32()
+			for replicas := d.int32(); replicas > 0 && d.err == nil; replicas-- {
+				d.int32()
+			}
+			for isr := d.int32(); isr > 0 && d.err == nil; isr-- {
+				d.int32()
+			}
+			if _, ok := brokers[leader]; ok {
+				partitions = append(partitions, kafkaPartition{index: index, leader: leader})
+			}
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("no partition of topic '%s' has a leader", c.conf.Topic)
+	}
+
+	c.brokers = brokers
+	c.partitions = partitions
+	return nil
+}
+
+func (c *kafkaClient) dial(address string) (*kafkaConn, error) {
+	dialer := &net.Dialer{Timeout: c.conf.TimeoutDuration}
+
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kc := &kafkaConn{
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		clientID: c.conf.ClientID,
+		timeout:  c.conf.TimeoutDuration,
+	}
+	if c.conf.SASL.Mechanism != "" {
+		if err := kc.authenticate(&c.conf.SASL); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return kc, nil
+}
+
+// reset closes all connections and forgets the partitions, so that they
+// are looked up again.
+func (c *kafkaClient) reset() {
+	for id, conn := range c.conns {
+		conn.conn.Close()
+		delete(c.conns, id)
+	}
+	c.partitions = nil
+}
+
+// recordBatch encodes the messages into a record batch of the kafka
+// message format v2, uncompressed and without keys or headers.
+func recordBatch(messages [][]byte, now time.Time) []byte {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+
+	records := kafkaEncoder{}
+	for i, message := range messages {
+		record := kafkaEncoder{}
+		record.int8(0)          // attributes
+		record.varint(0)        // timestamp delta
+		record.varint(int64(i)) // offset delta
+		record.varint(-1)       // null key
+		record.varint(int64(len(message)))
+		record.buf = append(record.buf, message...)
+		record.varint(0) // headers
+
+		records.varint(int64(len(record.buf)))
+		records.buf = append(records.buf, record.buf...)
+	}
+
+	// Fields covered by the CRC, from the attributes onwards
+	crced := kafkaEncoder{}
+	crced.int16(0) // attributes
+	crced.int32(int32(len(messages) - 1))
+	crced.int64(timestamp)
+	crced.int64(timestamp)
+	crced.int64(-1) // producer id
+	crced.int16(-1) // producer epoch
+	crced.int32(-1) // base sequence
+	crced.int32(int32(len(messages)))
+	crced.buf = append(crced.buf, records.buf...)
+
+	batch := kafkaEncoder{}
+	batch.int64(0) // base offset
+	batch.int32(int32(4 + 1 + 4 + len(crced.buf)))
+	batch.int32(-1) // partition leader epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(crced.buf, crc32c)))
+	batch.buf = append(batch.buf, crced.buf...)
+	return batch.buf
+}
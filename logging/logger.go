@@ -2,8 +2,11 @@ package logging
 
 import (
 	"log"
+	"os"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/saidmithilesh/hodor/config"
 )
@@ -18,19 +21,65 @@ var Logger *zap.Logger
 // their logs to a centralised location, it is important to be able
 // to distinguish between the logs produced by each instance to be able
 // pinpoint the source of each log line.
+//
+// Log lines below the configured log level are dropped and the rest are
+// written to the sink selected by the log output.
 func BuildLogger(conf *config.Config) {
-	loggerConfig := zap.NewProductionConfig()
-	loggerConfig.InitialFields = map[string]interface{}{
-		"gatewayId":   conf.Gateway.ID,
-		"instanceId":  conf.Gateway.InstanceID,
-		"gatewayName": conf.Gateway.Name,
+	sink, err := newSink(&conf.Gateway)
+	if err != nil {
+		log.Fatalf("Error while instantiating logger :: %s", err)
 	}
 
-	logger, err := loggerConfig.Build()
-	if err != nil {
-		log.Fatalf("Error while instantiating logger :: %#v", err)
+	encoderConfig := zap.NewProductionEncoderConfig()
+	if conf.Gateway.LogOutput == config.LogOutputELK {
+		// Kibana expects the time of log lines in @timestamp
+		encoderConfig.TimeKey = "@timestamp"
+		encoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+		}
+	}
+
+	encoder := zapcore.NewJSONEncoder(encoderConfig)
+	core := zapcore.NewCore(encoder, sink, level(conf.Gateway.LogLevel))
+	// Same sampling policy as zap's production config
+	core = zapcore.NewSampler(core, time.Second, 100, 100)
+
+	Logger = zap.New(
+		core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+		gatewayFields(conf),
+	)
+	Logger.Info("Logger initialised", zap.String("logLevel", conf.Gateway.LogLevel), zap.String("logOutput", conf.Gateway.LogOutput))
+
+	if conf.Gateway.AccessLog.Enabled {
+		if err := buildAccessLog(conf, encoder, sink); err != nil {
+			Logger.Fatal("Error while instantiating access logger", zap.Error(err))
+		}
 	}
+}
 
-	Logger = logger
-	Logger.Info("Logger initialised")
+// gatewayFields identifies the gateway instance in every log line.
+func gatewayFields(conf *config.Config) zap.Option {
+	return zap.Fields(
+		zap.Uint("gatewayId", conf.Gateway.ID),
+		zap.Uint("instanceId", conf.Gateway.InstanceID),
+		zap.String("gatewayName", conf.Gateway.Name),
+	)
+}
+
+// level maps the (already optimised) log level from the config onto
+// the corresponding zap level.
+func level(logLevel string) zapcore.Level {
+	switch logLevel {
+	case config.LogLevelDebug:
+		return zapcore.DebugLevel
+	case config.LogLevelWarning:
+		return zapcore.WarnLevel
+	case config.LogLevelError:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
 }
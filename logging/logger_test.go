@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// testLoggerConfig configures the log level provided as a format argument.
+const testLoggerConfig = `gateway:
+  id: 1
+  name: "test"
+  port: ":8080"
+  log_level: %q
+  endpoints:
+  - id: 1
+    name: "users"
+    method: GET
+    path: /users
+    backend: "http://127.0.0.1:1"
+`
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		logLevel string
+		level    zapcore.Level
+	}{
+		{"", zapcore.InfoLevel},
+		{"debug", zapcore.DebugLevel},
+		{"Info", zapcore.InfoLevel},
+		{"WARNING", zapcore.WarnLevel},
+		{"error", zapcore.ErrorLevel},
+	}
+
+	for _, test := range tests {
+		conf, err := config.Parse([]byte(fmt.Sprintf(testLoggerConfig, test.logLevel)))
+		if err != nil {
+			t.Fatalf("log level '%s' :: invalid test config :: %s", test.logLevel, err)
+		}
+		if got := level(conf.Gateway.LogLevel); got != test.level {
+			t.Errorf("log level '%s' :: expected %s, got %s", test.logLevel, test.level, got)
+		}
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hodor-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	batch := config.LogBatchConfig{
+		BufferSize:            10,
+		BatchSize:             10,
+		FlushIntervalDuration: time.Second,
+		TimeoutDuration:       time.Second,
+	}
+
+	// sinkOutput names the output a sink writes to
+	sinkOutput := func(sink zapcore.WriteSyncer) string {
+		switch sink.(type) {
+		case *FileSink:
+			return config.LogOutputFile
+		case *KafkaSink:
+			return config.LogOutputKafka
+		case *ELKSink:
+			return config.LogOutputELK
+		default:
+			return config.LogOutputStdio
+		}
+	}
+
+	outputs := []string{config.LogOutputStdio, config.LogOutputFile, config.LogOutputKafka, config.LogOutputELK, "SYSLOG"}
+	for _, output := range outputs {
+		gc := &config.GatewayConfig{LogOutput: output}
+		gc.LogFile.Path = filepath.Join(dir, "hodor.log")
+		gc.LogCredentials.Kafka = config.KafkaLogConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "logs", LogBatchConfig: batch}
+		gc.LogCredentials.ELK = config.ELKLogConfig{URLs: []string{"http://127.0.0.1:1"}, Index: "hodor", LogBatchConfig: batch}
+
+		sink, err := newSink(gc)
+		if output == "SYSLOG" {
+			if err == nil {
+				t.Errorf("%s :: expected an unknown output to be rejected", output)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s :: %s", output, err)
+		}
+		if got := sinkOutput(sink); got != output {
+			t.Errorf("%s :: expected a sink writing to %s, got %T", output, output, sink)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reopenOnSignal reopens the log file whenever the process receives a
+// SIGUSR1, as sent by logrotate's postrotate scripts.
+func reopenOnSignal(fs *FileSink) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	go func() {
+		for range signals {
+			if err := fs.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error while reopening log file '%s' :: %s\n", fs.Path, err)
+			}
+		}
+	}()
+}
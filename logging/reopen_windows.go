@@ -0,0 +1,4 @@
+package logging
+
+// reopenOnSignal is a no-op on windows, which has no SIGUSR1.
+func reopenOnSignal(fs *FileSink) {}
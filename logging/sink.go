@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// newSink creates the sink log lines are written to for the (already
+// optimised) log output of the gateway.
+func newSink(gc *config.GatewayConfig) (zapcore.WriteSyncer, error) {
+	switch gc.LogOutput {
+	case config.LogOutputStdio:
+		return zapcore.Lock(os.Stderr), nil
+
+	case config.LogOutputFile:
+		fs, err := NewFileSink(&gc.LogFile)
+		if err != nil {
+			return nil, err
+		}
+		reopenOnSignal(fs)
+		return fs, nil
+
+	case config.LogOutputKafka:
+		return NewKafkaSink(&gc.LogCredentials.Kafka)
+
+	case config.LogOutputELK:
+		return NewELKSink(&gc.LogCredentials.ELK)
+
+	default:
+		return nil, fmt.Errorf("unknown log output '%s'", gc.LogOutput)
+	}
+}
+
+// tlsClientConfig creates the TLS config to connect to remote log outputs
+// with.
+func tlsClientConfig(conf *config.TLSClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify}
+
+	if conf.CACertFilePath != "" {
+		pem, err := ioutil.ReadFile(conf.CACertFilePath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in '%s'", conf.CACertFilePath)
+		}
+	}
+
+	if conf.CertFilePath != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFilePath, conf.KeyFilePath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
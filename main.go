@@ -1,22 +1,100 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
 	"github.com/saidmithilesh/hodor/config"
 	"github.com/saidmithilesh/hodor/gateway"
 	"github.com/saidmithilesh/hodor/logging"
 )
 
+const usage = `Usage: hodor [command] [flags]
+
+Commands:
+  serve     Run the gateway (default)
+  validate  Validate the config file and report the errors found
+  routes    Print the route table, or with 'routes match <method> <path>',
+            the endpoint a request would be routed to
+
+Run 'hodor <command> -h' for the flags of a command.
+`
+
 func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "validate":
+		validate(args)
+	case "routes":
+		routes(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// newFlagSet creates the flags of a command, all of which take the
+// config file through the -config flag.
+func newFlagSet(command string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet("hodor "+command, flag.ExitOnError)
+	configFilePath := flags.String(
+		"config",
+		"./config.yml",
+		"Absolute or relative path of the API Gateway configuration file",
+	)
+	return flags, configFilePath
+}
+
+// serve runs the gateway until it is shut down.
+func serve(args []string) {
+	flags, configFilePath := newFlagSet("serve")
+	flags.Parse(args)
+
 	// Load configuration from configuration file
-	conf := config.LoadConfig()
+	conf, err := config.Load(*configFilePath, config.WithMiddlewareLookup(gateway.MiddlewareRegistered))
+	if err != nil {
+		reportConfigError(err)
+	}
 
 	// Build the logger so that it can be imported and used
 	// by other modules.
-	logging.BuildLogger(&conf)
+	logging.BuildLogger(conf)
 
 	// Construct the gateway object, build it and start
 	// running it
 	g := &gateway.Gateway{}
-	g = g.Build(&conf)
+	g = g.Build(conf)
 	g.Start()
+
+	// Flush the log lines still buffered by the log outputs
+	// once the gateway has shut down
+	if logging.AccessLog != nil {
+		logging.AccessLog.Sync()
+	}
+	logging.Logger.Sync()
+}
+
+// reportConfigError logs the errors found in the config and exits.
+func reportConfigError(err error) {
+	errs, ok := err.(config.ValidationErrors)
+	if !ok {
+		log.Fatalf("Error while loading the config file :: %s", err)
+	}
+
+	for _, err := range errs {
+		log.Printf("\t - %s\n", err)
+	}
+	log.Print("\n\n")
+	log.Fatalln("InvalidConfigError :: One or more validation rules failed. Please fix the above errors in the configuration file before continuing.")
 }
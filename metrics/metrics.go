@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics holds all the metrics collected by the gateway. Metrics about
+// requests are labelled by the id, name and method of the endpoint.
+type Metrics struct {
+	Registry *Registry
+
+	Requests           *CounterVec
+	RequestDuration    *HistogramVec
+	InFlight           *GaugeVec
+	UpstreamErrors     *CounterVec
+	RateLimited        *CounterVec
+	MiddlewareDuration *HistogramVec
+}
+
+// New creates the metrics of the gateway, labelled with the id of the
+// gateway and of the instance.
+func New(gc *config.GatewayConfig) *Metrics {
+	r := NewRegistry(
+		"gateway_id", strconv.FormatUint(uint64(gc.ID), 10),
+		"instance_id", strconv.FormatUint(uint64(gc.InstanceID), 10),
+	)
+
+	return &Metrics{
+		Registry: r,
+		Requests: r.NewCounterVec(
+			"hodor_requests_total",
+			"Requests handled, by endpoint and status class of the response.",
+			"endpoint_id", "endpoint_name", "method", "status_class",
+		),
+		RequestDuration: r.NewHistogramVec(
+			"hodor_request_duration_seconds",
+			"Time taken to respond to requests, by endpoint and status class of the response.",
+			DefaultBuckets,
+			"endpoint_id", "endpoint_name", "method", "status_class",
+		),
+		InFlight: r.NewGaugeVec(
+			"hodor_requests_in_flight",
+			"Requests being handled, by endpoint.",
+			"endpoint_id", "endpoint_name", "method",
+		),
+		UpstreamErrors: r.NewCounterVec(
+			"hodor_upstream_errors_total",
+			"Requests that could not be proxied to the backend, by endpoint and reason (timeout or error).",
+			"endpoint_id", "endpoint_name", "method", "reason",
+		),
+		RateLimited: r.NewCounterVec(
+			"hodor_rate_limited_total",
+			"Requests rejected for exceeding a rate limit, by endpoint and limiter.",
+			"endpoint_id", "endpoint_name", "method", "limiter",
+		),
+		MiddlewareDuration: r.NewHistogramVec(
+			"hodor_middleware_duration_seconds",
+			"Time taken by calls to HTTP middleware, by endpoint and middleware.",
+			DefaultBuckets,
+			"endpoint_id", "endpoint_name", "method", "middleware",
+		),
+	}
+}
+
+// StatusClass returns the class of a status code. Ex: 2xx.
+func StatusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
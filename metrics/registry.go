@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// collector is a family of metrics exposed by a Registry.
+type collector interface {
+	write(w *bufio.Writer, constLabels string)
+}
+
+// Registry exposes metrics in the Prometheus text format. Every metric
+// of the registry carries its constant labels.
+type Registry struct {
+	constLabels string
+
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates a Registry with the given constant label names and
+// values, in pairs.
+func NewRegistry(constLabels ...string) *Registry {
+	var labels []string
+	for i := 0; i+1 < len(constLabels); i += 2 {
+		labels = append(labels, constLabels[i]+"="+quote(constLabels[i+1]))
+	}
+	return &Registry{constLabels: strings.Join(labels, ",")}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// ServeHTTP writes all metrics of the registry in the Prometheus text
+// exposition format.
+func (r *Registry) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	w := bufio.NewWriter(res)
+	for _, c := range collectors {
+		c.write(w, r.constLabels)
+	}
+	w.Flush()
+}
+
+// family holds the metrics of a vector, one per combination of label values.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu      sync.RWMutex
+	metrics map[string]interface{}
+}
+
+func newFamily(name, help, kind string, labels []string) *family {
+	return &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		metrics: make(map[string]interface{}),
+	}
+}
+
+// get returns the metric for the label values, creating it if needed.
+func (f *family) get(values []string, create func() interface{}) interface{} {
+	key := strings.Join(values, "\xff")
+
+	f.mu.RLock()
+	m, ok := f.metrics[key]
+	f.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if m, ok = f.metrics[key]; !ok {
+		m = create()
+		f.metrics[key] = m
+	}
+	return m
+}
+
+// each calls fn with the rendered labels and the metric for each label
+// combination, in a stable order.
+func (f *family) each(w *bufio.Writer, constLabels string, fn func(labels string, m interface{})) {
+	f.mu.RLock()
+	keys := make([]string, 0, len(f.metrics))
+	for key := range f.metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	metrics := make([]interface{}, len(keys))
+	for i, key := range keys {
+		metrics[i] = f.metrics[key]
+	}
+	f.mu.RUnlock()
+
+	w.WriteString("# HELP " + f.name + " " + helpEscaper.Replace(f.help) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+	for i, key := range keys {
+		labels := []string{}
+		if constLabels != "" {
+			labels = append(labels, constLabels)
+		}
+		if len(f.labels) > 0 {
+			for j, value := range strings.Split(key, "\xff") {
+				labels = append(labels, f.labels[j]+"="+quote(value))
+			}
+		}
+		fn(strings.Join(labels, ","), metrics[i])
+	}
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	value uint64
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// CounterVec is a family of counters partitioned by label values.
+type CounterVec struct {
+	*family
+}
+
+// NewCounterVec creates a CounterVec and registers it with the registry.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newFamily(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// With returns the counter for the label values, in the order of the
+// label names of the vector.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.get(values, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+func (c *CounterVec) write(w *bufio.Writer, constLabels string) {
+	c.each(w, constLabels, func(labels string, m interface{}) {
+		writeSample(w, c.name, labels, float64(atomic.LoadUint64(&m.(*Counter).value)))
+	})
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	value int64
+}
+
+// Inc increments the gauge by one.
+func (g *Gauge) Inc() {
+	atomic.AddInt64(&g.value, 1)
+}
+
+// Dec decrements the gauge by one.
+func (g *Gauge) Dec() {
+	atomic.AddInt64(&g.value, -1)
+}
+
+// GaugeVec is a family of gauges partitioned by label values.
+type GaugeVec struct {
+	*family
+}
+
+// NewGaugeVec creates a GaugeVec and registers it with the registry.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newFamily(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// With returns the gauge for the label values, in the order of the label
+// names of the vector.
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return g.get(values, func() interface{} { return &Gauge{} }).(*Gauge)
+}
+
+func (g *GaugeVec) write(w *bufio.Writer, constLabels string) {
+	g.each(w, constLabels, func(labels string, m interface{}) {
+		writeSample(w, g.name, labels, float64(atomic.LoadInt64(&m.(*Gauge).value)))
+	})
+}
+
+// Histogram counts observations into buckets.
+type Histogram struct {
+	upperBounds []float64
+	// counts of the observations falling into each bucket, and
+	// beyond the last one
+	counts  []uint64
+	sumBits uint64
+}
+
+// Observe adds an observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	atomic.AddUint64(&h.counts[sort.SearchFloat64s(h.upperBounds, v)], 1)
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, sum) {
+			break
+		}
+	}
+}
+
+// HistogramVec is a family of histograms partitioned by label values.
+type HistogramVec struct {
+	*family
+	buckets []float64
+}
+
+// NewHistogramVec creates a HistogramVec with the given bucket upper
+// bounds, in increasing order, and registers it with the registry.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{newFamily(name, help, "histogram", labels), buckets}
+	r.register(h)
+	return h
+}
+
+// With returns the histogram for the label values, in the order of the
+// label names of the vector.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.get(values, func() interface{} {
+		return &Histogram{upperBounds: h.buckets, counts: make([]uint64, len(h.buckets)+1)}
+	}).(*Histogram)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer, constLabels string) {
+	h.each(w, constLabels, func(labels string, m interface{}) {
+		hist := m.(*Histogram)
+		sep := ""
+		if labels != "" {
+			sep = ","
+		}
+
+		var cumulative uint64
+		for i, upperBound := range hist.upperBounds {
+			cumulative += atomic.LoadUint64(&hist.counts[i])
+			writeSample(w, h.name+"_bucket", labels+sep+`le="`+formatFloat(upperBound)+`"`, float64(cumulative))
+		}
+		count := cumulative + atomic.LoadUint64(&hist.counts[len(hist.upperBounds)])
+		writeSample(w, h.name+"_bucket", labels+sep+`le="+Inf"`, float64(count))
+		writeSample(w, h.name+"_sum", labels, math.Float64frombits(atomic.LoadUint64(&hist.sumBits)))
+		writeSample(w, h.name+"_count", labels, float64(count))
+	})
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// helpEscaper and labelEscaper escape help texts and label values as the
+// text format requires.
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// quote quotes a label value, escaping it as the text format requires.
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
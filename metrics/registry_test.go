@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := NewRegistry("gateway_id", "1")
+
+	requests := r.NewCounterVec("test_requests_total", "Requests handled.", "path")
+	requests.With(`/a"b\c` + "\nd").Inc()
+	requests.With("/users").Inc()
+	requests.With("/users").Inc()
+
+	inFlight := r.NewGaugeVec("test_in_flight", `In flight \ requests`+"\nby path.")
+	inFlight.With().Inc()
+	inFlight.With().Inc()
+	inFlight.With().Dec()
+
+	duration := r.NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.125, 0.5, 1, 2.5}, "path")
+	for _, v := range []float64{0.0625, 0.125, 0.25, 1, 3} {
+		duration.With("/users").Observe(v)
+	}
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := res.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("expected the text format content type, got '%s'", got)
+	}
+
+	want := strings.Join([]string{
+		`# HELP test_requests_total Requests handled.`,
+		`# TYPE test_requests_total counter`,
+		`test_requests_total{gateway_id="1",path="/a\"b\\c\nd"} 1`,
+		`test_requests_total{gateway_id="1",path="/users"} 2`,
+		`# HELP test_in_flight In flight \\ requests\nby path.`,
+		`# TYPE test_in_flight gauge`,
+		`test_in_flight{gateway_id="1"} 1`,
+		`# HELP test_duration_seconds Durations.`,
+		`# TYPE test_duration_seconds histogram`,
+		`test_duration_seconds_bucket{gateway_id="1",path="/users",le="0.125"} 2`,
+		`test_duration_seconds_bucket{gateway_id="1",path="/users",le="0.5"} 3`,
+		`test_duration_seconds_bucket{gateway_id="1",path="/users",le="1"} 4`,
+		`test_duration_seconds_bucket{gateway_id="1",path="/users",le="2.5"} 4`,
+		`test_duration_seconds_bucket{gateway_id="1",path="/users",le="+Inf"} 5`,
+		`test_duration_seconds_sum{gateway_id="1",path="/users"} 4.4375`,
+		`test_duration_seconds_count{gateway_id="1",path="/users"} 5`,
+	}, "\n") + "\n"
+	if got := res.Body.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestRegistryWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	r.NewHistogramVec("test_duration_seconds", "Durations.", []float64{1}).With().Observe(1)
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := strings.Join([]string{
+		`# HELP test_duration_seconds Durations.`,
+		`# TYPE test_duration_seconds histogram`,
+		`test_duration_seconds_bucket{le="1"} 1`,
+		`test_duration_seconds_bucket{le="+Inf"} 1`,
+		`test_duration_seconds_sum 1`,
+		`test_duration_seconds_count 1`,
+	}, "\n") + "\n"
+	if got := res.Body.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// Result describes the outcome of a single rate limiter check. When a
+// request is rejected, RetryAfter holds the duration after which the
+// client may try again.
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Limiter implements a sliding window rate limiter. Rather than storing
+// the timestamp of every request, it keeps a counter for the current and
+// the previous fixed window and weighs the previous one by how much of it
+// still overlaps with the sliding window. This keeps memory usage constant
+// per key while closely approximating a true sliding log.
+// If the limiter is configured with a penalty, clients exceeding the limit
+// are blocked for the entire penalty duration, regardless of how their
+// request rate evolves in the meantime.
+// The limiter does not know how requests map to keys, KeyType and KeyName
+// only describe how its callers are expected to derive them.
+type Limiter struct {
+	Name     string
+	KeyType  string
+	KeyName  string
+	Requests uint64
+	Window   time.Duration
+	Penalty  time.Duration
+	Store    Store
+}
+
+// NewLimiter creates a Limiter from the (already optimised) rate limiter
+// config. The name is used to namespace the keys the limiter keeps in
+// the store.
+func NewLimiter(name string, conf *config.RateLimiterConfig, store Store) *Limiter {
+	l := &Limiter{
+		Name:     name,
+		KeyType:  conf.KeyType,
+		KeyName:  conf.KeyName,
+		Requests: uint64(conf.Requests),
+		Window:   conf.WindowDuration,
+		Store:    store,
+	}
+
+	if conf.PenaltyEnabled {
+		l.Penalty = conf.PenaltyDuration
+	}
+
+	return l
+}
+
+// Allow records a request for the given key and reports whether it is
+// within the configured limit. If the store fails, the request is
+// allowed and the error returned so that an unavailable store never
+// takes the gateway down with it.
+func (l *Limiter) Allow(key string) (Result, error) {
+	key = l.Name + ":" + key
+
+	if l.Penalty > 0 {
+		remaining, err := l.Store.Blocked(key)
+		if err != nil {
+			return Result{Allowed: true}, err
+		}
+		if remaining > 0 {
+			return Result{Allowed: false, RetryAfter: remaining}, nil
+		}
+	}
+
+	now := time.Now()
+	slot := now.UnixNano() / int64(l.Window)
+	elapsed := now.Sub(time.Unix(0, slot*int64(l.Window)))
+	weight := 1 - float64(elapsed)/float64(l.Window)
+
+	counts, ok, err := l.Store.Increment(key, slot, weight, l.Requests, 2*l.Window)
+	if err != nil {
+		return Result{Allowed: true}, err
+	}
+	if ok {
+		return Result{Allowed: true}, nil
+	}
+
+	if l.Penalty > 0 {
+		if err := l.Store.Block(key, l.Penalty); err != nil {
+			return Result{Allowed: false, RetryAfter: l.Penalty}, err
+		}
+		return Result{Allowed: false, RetryAfter: l.Penalty}, nil
+	}
+
+	return Result{
+		Allowed:    false,
+		RetryAfter: retryAfter(counts.Previous, counts.Current, l.Requests, elapsed, l.Window),
+	}, nil
+}
+
+// retryAfter computes how long a client has to wait until the sliding
+// window estimate drops below the limit again.
+func retryAfter(previous, current, limit uint64, elapsed, size time.Duration) time.Duration {
+	// The previous window alone keeps the estimate above the limit, wait
+	// until enough of it has slid out of the window.
+	if current < limit && previous > 0 {
+		fraction := 1 - float64(limit-current)/float64(previous)
+		return time.Duration(math.Ceil(fraction*float64(size))) - elapsed
+	}
+
+	// The current window is full, wait for it to become the previous one
+	// and for enough of it to slide out.
+	fraction := 1 - float64(limit)/float64(current)
+	return size - elapsed + time.Duration(math.Ceil(fraction*float64(size)))
+}
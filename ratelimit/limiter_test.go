@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// recordingStore is a Store that allows every hit and records the
+// arguments of the last Increment.
+type recordingStore struct {
+	MemoryStore
+	key    string
+	slot   int64
+	weight float64
+	limit  uint64
+	ttl    time.Duration
+}
+
+func (r *recordingStore) Increment(key string, slot int64, weight float64, limit uint64, ttl time.Duration) (Counts, bool, error) {
+	r.key, r.slot, r.weight, r.limit, r.ttl = key, slot, weight, limit, ttl
+	return Counts{}, true, nil
+}
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	store := &recordingStore{}
+	limiter := &Limiter{Name: "users", Requests: 10, Window: time.Minute, Store: store}
+
+	before := time.Now()
+	if result, err := limiter.Allow("127.0.0.1"); err != nil || !result.Allowed {
+		t.Fatalf("expected the request to be allowed, got %+v and %v", result, err)
+	}
+	after := time.Now()
+
+	if store.key != "users:127.0.0.1" {
+		t.Errorf("expected the key to be namespaced by the limiter, got '%s'", store.key)
+	}
+	if store.limit != 10 || store.ttl != 2*time.Minute {
+		t.Errorf("expected a limit of 10 kept for 2m, got %d kept for %s", store.limit, store.ttl)
+	}
+
+	// The previous window weighs as much as it still overlaps with the
+	// sliding window ending now
+	window := int64(time.Minute)
+	if store.slot != before.UnixNano()/window && store.slot != after.UnixNano()/window {
+		t.Fatalf("expected the slot of the current window, got %d", store.slot)
+	}
+	start := time.Unix(0, store.slot*window)
+	highest := 1 - float64(before.Sub(start))/float64(window)
+	lowest := 1 - float64(after.Sub(start))/float64(window)
+	if store.weight < lowest || store.weight > highest {
+		t.Errorf("expected a weight between %f and %f, got %f", lowest, highest, store.weight)
+	}
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	limiter := &Limiter{Name: "users", Requests: 1, Window: time.Hour, Store: NewMemoryStore()}
+
+	if result, _ := limiter.Allow("127.0.0.1"); !result.Allowed {
+		t.Fatalf("expected the first request to be allowed")
+	}
+	result, err := limiter.Allow("127.0.0.1")
+	if err != nil || result.Allowed {
+		t.Fatalf("expected the second request to be rejected, got %+v and %v", result, err)
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > time.Hour {
+		t.Errorf("expected to retry within the window, got %s", result.RetryAfter)
+	}
+}
+
+func TestLimiterPenalty(t *testing.T) {
+	limiter := &Limiter{Name: "users", Requests: 1, Window: time.Hour, Penalty: 30 * time.Second, Store: NewMemoryStore()}
+
+	if result, _ := limiter.Allow("127.0.0.1"); !result.Allowed {
+		t.Fatalf("expected the first request to be allowed")
+	}
+	if result, _ := limiter.Allow("127.0.0.1"); result.Allowed || result.RetryAfter != 30*time.Second {
+		t.Fatalf("expected the client to be blocked for 30s, got %+v", result)
+	}
+
+	// Blocked clients are turned away for the rest of the penalty
+	result, _ := limiter.Allow("127.0.0.1")
+	if result.Allowed || result.RetryAfter <= 29*time.Second || result.RetryAfter > 30*time.Second {
+		t.Errorf("expected the client to remain blocked for about 30s, got %+v", result)
+	}
+	if result, _ := limiter.Allow("127.0.0.2"); !result.Allowed {
+		t.Errorf("expected other clients not to be penalised")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name              string
+		previous, current uint64
+		elapsed, want     time.Duration
+	}{
+		// 10 * (1 - e/10s) + 5 drops below 10 after 5s
+		{"previous window slides out", 10, 5, 2 * time.Second, 3 * time.Second},
+		{"current window full", 0, 10, 4 * time.Second, 6 * time.Second},
+		{"current window full with previous", 4, 10, 4 * time.Second, 6 * time.Second},
+		// Half of the current window has to slide out of the next one
+		{"current window over limit", 0, 20, 4 * time.Second, 11 * time.Second},
+	}
+
+	for _, test := range tests {
+		got := retryAfter(test.previous, test.current, 10, test.elapsed, 10*time.Second)
+		if got != test.want {
+			t.Errorf("%s :: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+}
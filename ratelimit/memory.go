@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval at which the memory store drops
+// expired counters and penalties.
+const sweepInterval = time.Minute
+
+// window holds the hit counters for a single key. Slot identifies the
+// fixed window the current counter belongs to, Previous holds the count
+// of the window immediately preceding it.
+type window struct {
+	Slot     int64
+	Current  uint64
+	Previous uint64
+	Expires  time.Time
+}
+
+// MemoryStore is a Store that keeps its counters within the gateway
+// process. Limits are enforced per gateway instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	penalties map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		windows:   make(map[string]*window),
+		penalties: make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Increment implements Store.
+func (m *MemoryStore) Increment(key string, slot int64, weight float64, limit uint64, ttl time.Duration) (Counts, bool, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	w, ok := m.windows[key]
+	if !ok {
+		w = &window{Slot: slot}
+		m.windows[key] = w
+	}
+	w.advance(slot)
+
+	counts := Counts{Previous: w.Previous, Current: w.Current}
+	if float64(w.Previous)*weight+float64(w.Current) >= float64(limit) {
+		return counts, false, nil
+	}
+
+	w.Current++
+	w.Expires = now.Add(ttl)
+	return counts, true, nil
+}
+
+// Block implements Store.
+func (m *MemoryStore) Block(key string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.penalties[key] = time.Now().Add(d)
+	return nil
+}
+
+// Blocked implements Store.
+func (m *MemoryStore) Blocked(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.penalties[key]
+	if !ok {
+		return 0, nil
+	}
+
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(m.penalties, key)
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// Close implements Store.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// advance moves the window forward to the given slot, shifting the
+// current count into the previous one when the slots are adjacent.
+func (w *window) advance(slot int64) {
+	switch {
+	case slot == w.Slot:
+		return
+	case slot == w.Slot+1:
+		w.Previous = w.Current
+	default:
+		w.Previous = 0
+	}
+	w.Current = 0
+	w.Slot = slot
+}
+
+// sweep drops windows and penalties that can no longer affect a decision.
+// It runs at most once per sweepInterval to keep the cost off the hot path.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, w := range m.windows {
+		if now.After(w.Expires) {
+			delete(m.windows, key)
+		}
+	}
+	for key, until := range m.penalties {
+		if !now.Before(until) {
+			delete(m.penalties, key)
+		}
+	}
+}
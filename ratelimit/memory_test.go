@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreIncrement(t *testing.T) {
+	tests := []struct {
+		name    string
+		slot    int64
+		weight  float64
+		counts  Counts
+		allowed bool
+	}{
+		{"first hit", 1, 1, Counts{}, true},
+		{"second hit", 1, 1, Counts{Current: 1}, true},
+		{"limit reached", 1, 1, Counts{Current: 2}, false},
+		{"previous window weighed in", 2, 0.5, Counts{Previous: 2}, true},
+		{"weighed estimate reaches limit", 2, 0.5, Counts{Previous: 2, Current: 1}, false},
+		{"previous window slid out", 2, 0, Counts{Previous: 2, Current: 1}, true},
+		{"current window full", 2, 0, Counts{Previous: 2, Current: 2}, false},
+		{"skipped window resets", 4, 1, Counts{}, true},
+	}
+
+	m := NewMemoryStore()
+	for _, test := range tests {
+		counts, allowed, err := m.Increment("users", test.slot, test.weight, 2, time.Minute)
+		if err != nil {
+			t.Fatalf("%s :: unexpected error :: %s", test.name, err)
+		}
+		if counts != test.counts || allowed != test.allowed {
+			t.Errorf("%s :: expected %+v and %t, got %+v and %t", test.name, test.counts, test.allowed, counts, allowed)
+		}
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	m := NewMemoryStore()
+	if _, ok, _ := m.Increment("a", 1, 1, 1, time.Minute); !ok {
+		t.Fatalf("expected the first hit of a to be allowed")
+	}
+	if _, ok, _ := m.Increment("b", 1, 1, 1, time.Minute); !ok {
+		t.Errorf("expected the hits of b to be counted apart from a")
+	}
+	if _, ok, _ := m.Increment("a", 1, 1, 1, time.Minute); ok {
+		t.Errorf("expected the second hit of a to be rejected")
+	}
+}
+
+func TestMemoryStoreBlock(t *testing.T) {
+	m := NewMemoryStore()
+	if remaining, _ := m.Blocked("users"); remaining != 0 {
+		t.Fatalf("expected no penalty, got %s", remaining)
+	}
+
+	m.Block("users", time.Minute)
+	if remaining, _ := m.Blocked("users"); remaining <= 59*time.Second || remaining > time.Minute {
+		t.Errorf("expected a penalty of about a minute, got %s", remaining)
+	}
+
+	m.Block("users", -time.Second)
+	if remaining, _ := m.Blocked("users"); remaining != 0 {
+		t.Errorf("expected the penalty to have expired, got %s", remaining)
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// incrementScript implements Store.Increment atomically on the redis
+// server. KEYS[1] and KEYS[2] hold the counters of the current and the
+// previous window, ARGV holds the weight, the limit and the ttl in
+// milliseconds.
+const incrementScript = `
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+if previous * tonumber(ARGV[1]) + current >= tonumber(ARGV[2]) then
+	return {0, previous, current}
+end
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, previous, current}
+`
+
+var incrementScriptSHA = func() string {
+	sum := sha1.Sum([]byte(incrementScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// RedisStore is a Store that keeps its counters on a redis server (or
+// any server speaking the redis protocol). All gateway instances pointed
+// at the same server share their counters, so limits are enforced
+// across the whole deployment.
+type RedisStore struct {
+	prefix string
+	client *redisClient
+}
+
+// NewRedisStore creates a RedisStore from the (already optimised) store
+// config. Connections are established lazily.
+func NewRedisStore(conf *config.RateLimitStoreConfig) *RedisStore {
+	return &RedisStore{
+		prefix: conf.KeyPrefix,
+		client: newRedisClient(
+			conf.Address,
+			conf.Password,
+			conf.Database,
+			conf.PoolSize,
+			conf.TimeoutDuration,
+		),
+	}
+}
+
+// Ping checks that the redis server is reachable.
+func (r *RedisStore) Ping() error {
+	_, err := r.client.Do("PING")
+	return err
+}
+
+// Increment implements Store.
+func (r *RedisStore) Increment(key string, slot int64, weight float64, limit uint64, ttl time.Duration) (Counts, bool, error) {
+	current := fmt.Sprintf("%s:%s:%d", r.prefix, key, slot)
+	previous := fmt.Sprintf("%s:%s:%d", r.prefix, key, slot-1)
+	args := []interface{}{2, current, previous, weight, limit, ttl.Milliseconds()}
+
+	reply, err := r.client.Do(append([]interface{}{"EVALSHA", incrementScriptSHA}, args...)...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		reply, err = r.client.Do(append([]interface{}{"EVAL", incrementScript}, args...)...)
+	}
+	if err != nil {
+		return Counts{}, false, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Counts{}, false, errors.New("unexpected reply to rate limiter script")
+	}
+
+	var numbers [3]int64
+	for i, v := range values {
+		if numbers[i], ok = v.(int64); !ok {
+			return Counts{}, false, errors.New("unexpected reply to rate limiter script")
+		}
+	}
+
+	counts := Counts{Previous: uint64(numbers[1]), Current: uint64(numbers[2])}
+	return counts, numbers[0] == 1, nil
+}
+
+// Block implements Store.
+func (r *RedisStore) Block(key string, d time.Duration) error {
+	_, err := r.client.Do("SET", r.penaltyKey(key), 1, "PX", d.Milliseconds())
+	return err
+}
+
+// Blocked implements Store.
+func (r *RedisStore) Blocked(key string) (time.Duration, error) {
+	reply, err := r.client.Do("PTTL", r.penaltyKey(key))
+	if err != nil {
+		return 0, err
+	}
+
+	ttl, ok := reply.(int64)
+	if !ok {
+		return 0, errors.New("unexpected reply to PTTL")
+	}
+
+	// PTTL returns negative values for missing keys
+	// and keys without an expiry
+	if ttl <= 0 {
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// Close implements Store.
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
+
+func (r *RedisStore) penaltyKey(key string) string {
+	return fmt.Sprintf("%s:penalty:%s", r.prefix, key)
+}
@@ -0,0 +1,312 @@
+package ratelimit
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// fakeRedis is an in-process server speaking RESP. It implements the
+// commands used by RedisStore, running the increment script natively,
+// and records every command it receives.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands []string
+	scripts  map[string]bool
+	values   map[string]int64
+	ttls     map[string]time.Duration
+	conns    []net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen :: %s", err)
+	}
+
+	f := &fakeRedis{
+		listener: listener,
+		scripts:  make(map[string]bool),
+		values:   make(map[string]int64),
+		ttls:     make(map[string]time.Duration),
+	}
+	go f.serve()
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Close stops the server and drops all open connections.
+func (f *fakeRedis) Close() {
+	f.listener.Close()
+	f.dropConnections()
+}
+
+// dropConnections closes the open connections, leaving the server up.
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+// Commands returns the names of the commands received so far.
+func (f *fakeRedis) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// Value returns the integer held at key.
+func (f *fakeRedis) Value(key string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values[key]
+}
+
+// TTL returns the expiry set on key, if any.
+func (f *fakeRedis) TTL(key string) (time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ttl, ok := f.ttls[key]
+	return ttl, ok
+}
+
+func (f *fakeRedis) store(conf *config.RateLimitStoreConfig) *RedisStore {
+	if conf == nil {
+		conf = &config.RateLimitStoreConfig{}
+	}
+	conf.Address = f.listener.Addr().String()
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = "hodor"
+	}
+	if conf.PoolSize == 0 {
+		conf.PoolSize = 2
+	}
+	if conf.TimeoutDuration == 0 {
+		conf.TimeoutDuration = time.Second
+	}
+	return NewRedisStore(conf)
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	// Commands are arrays of bulk strings, which the client side of
+	// the protocol decodes just as well
+	rc := &redisConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+	for {
+		request, err := rc.read()
+		if err != nil {
+			return
+		}
+		items, ok := request.([]interface{})
+		if !ok || len(items) == 0 {
+			return
+		}
+
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = string(item.([]byte))
+		}
+		fmt.Fprint(rc.writer, f.exec(args))
+		if rc.writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+// exec runs a single command and returns its encoded reply.
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	command := strings.ToUpper(args[0])
+	f.commands = append(f.commands, command)
+
+	switch command {
+	case "PING":
+		return "+PONG\r\n"
+
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+
+	case "EVALSHA":
+		if !f.scripts[args[1]] {
+			return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+		}
+		return f.increment(args[3], args[4], args[5:])
+
+	case "EVAL":
+		if args[1] != incrementScript {
+			return "-ERR unknown script\r\n"
+		}
+		f.scripts[incrementScriptSHA] = true
+		return f.increment(args[3], args[4], args[5:])
+
+	case "SET":
+		ttl, _ := strconv.ParseInt(args[4], 10, 64)
+		f.values[args[1]], _ = strconv.ParseInt(args[2], 10, 64)
+		f.ttls[args[1]] = time.Duration(ttl) * time.Millisecond
+		return "+OK\r\n"
+
+	case "PTTL":
+		ttl, ok := f.ttls[args[1]]
+		if !ok {
+			return ":-2\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", ttl.Milliseconds())
+
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", command)
+	}
+}
+
+// increment mirrors incrementScript.
+func (f *fakeRedis) increment(current, previous string, argv []string) string {
+	weight, _ := strconv.ParseFloat(argv[0], 64)
+	limit, _ := strconv.ParseFloat(argv[1], 64)
+	ttl, _ := strconv.ParseInt(argv[2], 10, 64)
+
+	c, p := f.values[current], f.values[previous]
+	if float64(p)*weight+float64(c) >= limit {
+		return fmt.Sprintf("*3\r\n:0\r\n:%d\r\n:%d\r\n", p, c)
+	}
+	f.values[current]++
+	f.ttls[current] = time.Duration(ttl) * time.Millisecond
+	return fmt.Sprintf("*3\r\n:1\r\n:%d\r\n:%d\r\n", p, c)
+}
+
+func TestRedisStoreIncrement(t *testing.T) {
+	f := newFakeRedis(t)
+	store := f.store(nil)
+	defer store.Close()
+
+	f.mu.Lock()
+	f.values["hodor:users:9"] = 4
+	f.mu.Unlock()
+	for i, want := range []struct {
+		counts  Counts
+		allowed bool
+	}{
+		{Counts{Previous: 4, Current: 0}, true},
+		{Counts{Previous: 4, Current: 1}, true},
+		{Counts{Previous: 4, Current: 2}, false},
+	} {
+		// 4 * 0.25 + 2 reaches the limit of 3 on the third hit
+		counts, allowed, err := store.Increment("users", 10, 0.25, 3, 20*time.Second)
+		if err != nil {
+			t.Fatalf("hit %d :: unexpected error :: %s", i, err)
+		}
+		if counts != want.counts || allowed != want.allowed {
+			t.Errorf("hit %d :: expected %+v and %t, got %+v and %t", i, want.counts, want.allowed, counts, allowed)
+		}
+	}
+
+	if got := f.Value("hodor:users:10"); got != 2 {
+		t.Errorf("expected 2 recorded hits, got %d", got)
+	}
+	if got, _ := f.TTL("hodor:users:10"); got != 20*time.Second {
+		t.Errorf("expected the counter to expire after 20s, got %s", got)
+	}
+	if _, ok := f.TTL("hodor:users:9"); ok {
+		t.Errorf("expected the previous counter to be left untouched")
+	}
+}
+
+func TestRedisStoreLoadsScript(t *testing.T) {
+	f := newFakeRedis(t)
+	store := f.store(nil)
+	defer store.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := store.Increment("users", 1, 0, 10, time.Second); err != nil {
+			t.Fatalf("unexpected error :: %s", err)
+		}
+	}
+
+	// The script is only sent in full when the server does not know it
+	want := []string{"EVALSHA", "EVAL", "EVALSHA"}
+	if got := f.Commands(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected commands %v, got %v", want, got)
+	}
+}
+
+func TestRedisStoreBlock(t *testing.T) {
+	f := newFakeRedis(t)
+	store := f.store(nil)
+	defer store.Close()
+
+	if remaining, err := store.Blocked("users"); err != nil || remaining != 0 {
+		t.Fatalf("expected no penalty, got %s and %v", remaining, err)
+	}
+	if err := store.Block("users", 90*time.Second); err != nil {
+		t.Fatalf("unexpected error :: %s", err)
+	}
+	if got, _ := f.TTL("hodor:penalty:users"); got != 90*time.Second {
+		t.Errorf("expected the penalty to expire after 90s, got %s", got)
+	}
+	if remaining, err := store.Blocked("users"); err != nil || remaining != 90*time.Second {
+		t.Errorf("expected a penalty of 90s, got %s and %v", remaining, err)
+	}
+}
+
+func TestRedisStoreReconnects(t *testing.T) {
+	f := newFakeRedis(t)
+	store := f.store(nil)
+	defer store.Close()
+
+	if err := store.Ping(); err != nil {
+		t.Fatalf("unexpected error :: %s", err)
+	}
+
+	// The pooled connection is broken, it must be discarded rather than
+	// reused on the call after the failing one
+	f.dropConnections()
+	store.Ping()
+	if err := store.Ping(); err != nil {
+		t.Errorf("expected a fresh connection, got %s", err)
+	}
+}
+
+func TestLimiterFailsOpen(t *testing.T) {
+	f := newFakeRedis(t)
+	store := f.store(&config.RateLimitStoreConfig{TimeoutDuration: 100 * time.Millisecond})
+	defer store.Close()
+	f.Close()
+
+	limiter := &Limiter{Name: "users", Requests: 1, Window: time.Minute, Penalty: time.Minute, Store: store}
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow("127.0.0.1")
+		if err == nil {
+			t.Fatalf("expected the store error to be returned")
+		}
+		if !result.Allowed {
+			t.Fatalf("expected requests to be allowed while the store is down")
+		}
+	}
+}
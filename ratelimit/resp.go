@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisError is an error reply sent by the redis server. Unlike network
+// errors, it leaves the connection usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn is a single connection speaking the redis serialisation
+// protocol (RESP). Any server implementing RESP can be used as a store.
+type redisConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	timeout time.Duration
+}
+
+// redisClient is a minimal redis client holding a pool of connections.
+// Connections are dialled lazily and discarded on network errors.
+type redisClient struct {
+	address  string
+	password string
+	database uint
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+func newRedisClient(address, password string, database, poolSize uint, timeout time.Duration) *redisClient {
+	return &redisClient{
+		address:  address,
+		password: password,
+		database: database,
+		timeout:  timeout,
+		pool:     make(chan *redisConn, poolSize),
+	}
+}
+
+// Do sends a single command to the server and returns its reply.
+func (c *redisClient) Do(args ...interface{}) (interface{}, error) {
+	conn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		conn.conn.Close()
+		return nil, err
+	}
+
+	c.put(conn)
+	return reply, err
+}
+
+// Close closes all idle connections in the pool.
+func (c *redisClient) Close() error {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *redisClient) get() (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+		return c.dial()
+	}
+}
+
+func (c *redisClient) put(conn *redisConn) {
+	select {
+	case c.pool <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (c *redisClient) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{
+		conn:    netConn,
+		reader:  bufio.NewReader(netConn),
+		writer:  bufio.NewWriter(netConn),
+		timeout: c.timeout,
+	}
+
+	if c.password != "" {
+		if _, err := conn.do("AUTH", c.password); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if c.database != 0 {
+		if _, err := conn.do("SELECT", c.database); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (rc *redisConn) do(args ...interface{}) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(rc.timeout))
+
+	if err := rc.write(args); err != nil {
+		return nil, err
+	}
+	return rc.read()
+}
+
+// write encodes the command as a RESP array of bulk strings.
+func (rc *redisConn) write(args []interface{}) error {
+	fmt.Fprintf(rc.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case uint64:
+			s = strconv.FormatUint(v, 10)
+		case uint:
+			s = strconv.FormatUint(uint64(v), 10)
+		case int:
+			s = strconv.Itoa(v)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			s = fmt.Sprint(v)
+		}
+		fmt.Fprintf(rc.writer, "$%d\r\n%s\r\n", len(s), s)
+	}
+	return rc.writer.Flush()
+}
+
+// read decodes a single RESP reply. Simple strings are returned as
+// string, integers as int64, bulk strings as []byte (nil if absent)
+// and arrays as []interface{}.
+func (rc *redisConn) read() (interface{}, error) {
+	line, err := rc.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed redis reply")
+	}
+
+	prefix, payload := line[0], line[1:len(line)-2]
+	switch prefix {
+	case '+':
+		return payload, nil
+
+	case '-':
+		return nil, redisError(payload)
+
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rc.reader, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+
+	case '*':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			// Error replies nested in arrays are returned as values
+			// so that the rest of the array is still consumed
+			item, err := rc.read()
+			if _, ok := err.(redisError); err != nil && !ok {
+				return nil, err
+			}
+			if err != nil {
+				item = err
+			}
+			items[i] = item
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected redis reply type '%c'", prefix)
+	}
+}
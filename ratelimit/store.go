@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+// Counts holds the hit counters of the current and the previous fixed
+// window of a key, as observed by a Store.
+type Counts struct {
+	Previous uint64
+	Current  uint64
+}
+
+// Store is the storage backend of the rate limiters. It keeps the hit
+// counters of every key and the penalties levied on them. All methods
+// must be safe for concurrent use, and Increment must be atomic so that
+// multiple gateway instances sharing a store never exceed a limit.
+type Store interface {
+	// Increment records a hit for key in the given window slot, unless
+	// the sliding window estimate, computed as previous * weight + current,
+	// has already reached limit. It returns the counts observed before
+	// the hit and whether the hit was recorded. The counters of a slot
+	// expire after ttl.
+	Increment(key string, slot int64, weight float64, limit uint64, ttl time.Duration) (Counts, bool, error)
+
+	// Block levies a penalty on key for the given duration.
+	Block(key string, d time.Duration) error
+
+	// Blocked returns the remaining penalty on key, or zero if the key
+	// is not penalised.
+	Blocked(key string) (time.Duration, error)
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// NewStore creates the Store described by the (already optimised)
+// store config.
+func NewStore(conf *config.RateLimitStoreConfig) (Store, error) {
+	switch conf.Type {
+	case config.RLStoreMemory:
+		return NewMemoryStore(), nil
+
+	case config.RLStoreRedis:
+		return NewRedisStore(conf), nil
+
+	default:
+		return nil, fmt.Errorf("unknown rate limiter store type '%s'", conf.Type)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/gateway"
+)
+
+// routes prints the route table of the config file, or with the match
+// subcommand, the endpoint a request would be routed to.
+func routes(args []string) {
+	flags, configFilePath := newFlagSet("routes")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: hodor routes [flags]\n       hodor routes match [flags] <method> <path>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	args = parseInterspersed(flags, args)
+	match := len(args) > 0 && args[0] == "match"
+	if match {
+		args = args[1:]
+	}
+
+	if (match && len(args) != 2) || (!match && len(args) != 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	conf, err := config.Load(*configFilePath, config.WithMiddlewareLookup(gateway.MiddlewareRegistered))
+	if err != nil {
+		reportConfigError(err)
+	}
+
+	if match {
+		matchRoute(&conf.Gateway, args[0], args[1])
+		return
+	}
+	printRoutes(&conf.Gateway)
+}
+
+// parseInterspersed parses the flags found before, between and after the
+// positional arguments, which the flag package stops at, and returns the
+// positional arguments. Arguments following a '--' are all positional.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		if parsed := args[:len(args)-len(rest)]; len(parsed) > 0 && parsed[len(parsed)-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// printRoutes prints the method, path and backend of every endpoint,
+// along with the rate limits, CORS and auth config applicable to it.
+func printRoutes(gc *config.GatewayConfig) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tMETHOD\tPATH\tBACKEND\tRATE LIMIT\tCORS\tAUTH")
+	for i := range gc.Endpoints {
+		e := &gc.Endpoints[i]
+		fmt.Fprintf(
+			w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.ID, e.Name, e.Method, e.Path, e.Backend,
+			describeRateLimits(gc, e), describeCORS(gc, e), describeAuth(gc, e),
+		)
+	}
+	w.Flush()
+}
+
+// matchRoute prints the endpoint a request for the method and path would
+// be routed to and the values of its path parameters. Exits with a
+// non-zero status if the request matches no endpoint.
+func matchRoute(gc *config.GatewayConfig, method string, path string) {
+	method = strings.ToUpper(method)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	rt := config.NewRouteTable(gc)
+	e, params, redirect := rt.Match(method, path)
+	if e == nil {
+		fmt.Printf("No endpoint matches %s %s\n", method, path)
+		if redirect {
+			fmt.Println("The request would be redirected to the path with its trailing slash added or removed")
+		}
+		if allowed := rt.Allowed(path); len(allowed) > 0 {
+			fmt.Printf("The path is routed for %s, the request would be answered with a 405\n", strings.Join(allowed, ", "))
+		}
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Endpoint\t%d %s\n", e.ID, e.Name)
+	fmt.Fprintf(w, "Route\t%s %s\n", e.Method, e.Path)
+	fmt.Fprintf(w, "Backend\t%s\n", e.Backend)
+	for _, param := range params {
+		fmt.Fprintf(w, "Param\t%s = %s\n", param.Key, param.Value)
+	}
+	fmt.Fprintf(w, "Rate limit\t%s\n", describeRateLimits(gc, e))
+	fmt.Fprintf(w, "CORS\t%s\n", describeCORS(gc, e))
+	fmt.Fprintf(w, "Auth\t%s\n", describeAuth(gc, e))
+	w.Flush()
+}
+
+// describeRateLimits describes the rate limits applicable to an endpoint,
+// Ex: 100/1M by ip (gateway)
+func describeRateLimits(gc *config.GatewayConfig, e *config.EndpointConfig) string {
+	rateLimits, level := e.EnabledRateLimits(), config.CFLevelEndpoint
+	if len(rateLimits) == 0 {
+		rateLimits, level = gc.EnabledRateLimits(), config.CFLevelGateway
+	}
+	if len(rateLimits) == 0 {
+		return "-"
+	}
+
+	limits := make([]string, len(rateLimits))
+	for i, rl := range rateLimits {
+		keyBy := rl.KeyBy
+		if keyBy == "" {
+			keyBy = "all"
+		}
+		limits[i] = fmt.Sprintf("%d/%s by %s", rl.Requests, rl.WindowString, keyBy)
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(limits, ", "), level)
+}
+
+// describeCORS describes the CORS config applicable to an endpoint,
+// Ex: https://example.com (gateway)
+func describeCORS(gc *config.GatewayConfig, e *config.EndpointConfig) string {
+	cors := gc.CORSFor(e)
+	if cors == nil {
+		return "-"
+	}
+
+	level := config.CFLevelGateway
+	if cors == &e.CORS {
+		level = config.CFLevelEndpoint
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(cors.AllowedDomains, ", "), level)
+}
+
+// describeAuth describes the auth config applicable to an endpoint,
+// Ex: JWT (endpoint)
+func describeAuth(gc *config.GatewayConfig, e *config.EndpointConfig) string {
+	auth := gc.AuthFor(e)
+	if auth == nil {
+		return "-"
+	}
+
+	level := config.CFLevelGateway
+	if auth == &e.Auth {
+		level = config.CFLevelEndpoint
+	}
+	return fmt.Sprintf("%s (%s)", auth.Type, level)
+}
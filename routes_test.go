@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args       []string
+		config     string
+		positional []string
+	}{
+		{[]string{"-config", "a.yml", "match", "GET", "/users"}, "a.yml", []string{"match", "GET", "/users"}},
+		{[]string{"match", "GET", "/users", "-config", "a.yml"}, "a.yml", []string{"match", "GET", "/users"}},
+		{[]string{"match", "-config=a.yml", "GET", "/users"}, "a.yml", []string{"match", "GET", "/users"}},
+		{[]string{"match", "--", "GET", "-config"}, "./config.yml", []string{"match", "GET", "-config"}},
+		{nil, "./config.yml", nil},
+	}
+
+	for _, test := range tests {
+		flags, configFilePath := newFlagSet("routes")
+		flags.SetOutput(ioutil.Discard)
+		flags.Init("routes", flag.ContinueOnError)
+
+		positional := parseInterspersed(flags, test.args)
+		if *configFilePath != test.config || fmt.Sprint(positional) != fmt.Sprint(test.positional) {
+			t.Errorf("%v :: expected config '%s' and arguments %v, got '%s' and %v", test.args, test.config, test.positional, *configFilePath, positional)
+		}
+	}
+}
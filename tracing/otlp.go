@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/logging"
+)
+
+// exporter ships ended spans to the collector in batches, encoded as OTLP
+// JSON. Spans are queued in a bounded buffer by the requests and exported
+// by a background goroutine, so that requests never wait on the collector.
+// Spans are dropped and counted when the buffer is full or a batch cannot
+// be exported.
+type exporter struct {
+	// accessed atomically, kept first for 64 bit alignment
+	dropped uint64
+
+	endpoint      string
+	headers       map[string]string
+	resource      []attribute
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	client        *http.Client
+
+	spans chan *Span
+	syncs chan chan struct{}
+}
+
+func newExporter(conf *config.TracingConfig, resource []attribute) *exporter {
+	ex := &exporter{
+		endpoint:      conf.Endpoint,
+		headers:       conf.Headers,
+		resource:      resource,
+		batchSize:     conf.BatchSize,
+		flushInterval: conf.FlushIntervalDuration,
+		timeout:       conf.TimeoutDuration,
+		client:        &http.Client{Timeout: conf.TimeoutDuration},
+		spans:         make(chan *Span, conf.BufferSize),
+		syncs:         make(chan chan struct{}),
+	}
+	go ex.run()
+	return ex
+}
+
+// export queues the span. It never blocks, the span is dropped if the
+// buffer is full.
+func (ex *exporter) export(span *Span) {
+	select {
+	case ex.spans <- span:
+	default:
+		atomic.AddUint64(&ex.dropped, 1)
+	}
+}
+
+func (ex *exporter) sync() error {
+	done := make(chan struct{})
+	timeout := time.After(ex.timeout)
+
+	select {
+	case ex.syncs <- done:
+	case <-timeout:
+		return errors.New("timed out exporting spans")
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-timeout:
+		return errors.New("timed out exporting spans")
+	}
+}
+
+func (ex *exporter) run() {
+	ticker := time.NewTicker(ex.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, ex.batchSize)
+	var reported uint64
+	for {
+		select {
+		case span := <-ex.spans:
+			batch = append(batch, span)
+			if len(batch) >= ex.batchSize {
+				batch = ex.flush(batch)
+			}
+
+		case <-ticker.C:
+			batch = ex.flush(batch)
+
+			if dropped := atomic.LoadUint64(&ex.dropped); dropped != reported {
+				logging.Logger.Warn("Spans dropped", zap.Uint64("dropped", dropped))
+				reported = dropped
+			}
+
+		case done := <-ex.syncs:
+			for pending := len(ex.spans); pending > 0; pending-- {
+				batch = append(batch, <-ex.spans)
+				if len(batch) >= ex.batchSize {
+					batch = ex.flush(batch)
+				}
+			}
+			batch = ex.flush(batch)
+			close(done)
+		}
+	}
+}
+
+// flush exports the batch and returns it emptied for reuse.
+func (ex *exporter) flush(batch []*Span) []*Span {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := ex.send(batch); err != nil {
+		atomic.AddUint64(&ex.dropped, uint64(len(batch)))
+		logging.Logger.Error(
+			"Error while exporting spans",
+			zap.String("endpoint", ex.endpoint),
+			zap.Int("spans", len(batch)),
+			zap.Error(err),
+		)
+	}
+
+	for i := range batch {
+		batch[i] = nil
+	}
+	return batch[:0]
+}
+
+func (ex *exporter) send(batch []*Span) error {
+	body, err := json.Marshal(ex.encode(batch))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ex.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range ex.headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := ex.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("collector responded with status %d :: %s", res.StatusCode, message)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// The OTLP JSON encoding of a batch of spans. Ids are hex encoded and
+// 64 bit integers are strings, as required by OTLP/HTTP.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		TraceState        string          `json:"traceState,omitempty"`
+		Name              string          `json:"name"`
+		Kind              SpanKind        `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+func (ex *exporter) encode(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, len(batch))
+	for i, span := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           hex.EncodeToString(span.Context.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.Context.SpanID[:]),
+			TraceState:        span.Context.State,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.attributes),
+			Status:            otlpStatus{Code: span.statusCode, Message: span.statusMessage},
+		}
+		if span.ParentID != (SpanID{}) {
+			spans[i].ParentSpanID = hex.EncodeToString(span.ParentID[:])
+		}
+	}
+
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes(ex.resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "hodor"}, Spans: spans}},
+	}}}
+}
+
+func encodeAttributes(attributes []attribute) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attributes))
+	for _, attr := range attributes {
+		var value map[string]interface{}
+		switch v := attr.value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.key, Value: value})
+	}
+	return encoded
+}
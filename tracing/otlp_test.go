@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	ex := &exporter{resource: []attribute{
+		{key: "service.name", value: "hodor"},
+		{key: "hodor.gateway.id", value: int64(1)},
+	}}
+
+	start := time.Unix(1700000000, 5)
+	server := &Span{
+		Name: "GET /users",
+		Kind: SpanKindServer,
+		Context: SpanContext{
+			TraceID: TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:  SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			Sampled: true,
+			State:   "a=1",
+		},
+		start: start,
+		end:   start.Add(1500 * time.Millisecond),
+		attributes: []attribute{
+			{key: "http.method", value: "GET"},
+			{key: "http.status_code", value: 502},
+			{key: "hodor.retried", value: true},
+			{key: "hodor.ratio", value: 0.25},
+			{key: "hodor.other", value: []string{"a"}},
+		},
+		statusCode:    statusError,
+		statusMessage: "bad gateway",
+	}
+	client := &Span{
+		Name:     "proxy",
+		Kind:     SpanKindClient,
+		Context:  server.Context,
+		ParentID: server.Context.SpanID,
+		start:    start,
+		end:      start.Add(time.Second),
+	}
+	client.Context.SpanID = SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	client.Context.State = ""
+
+	body, err := json.Marshal(ex.encode([]*Span{server, client}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"resourceSpans":[{` +
+		`"resource":{"attributes":[` +
+		`{"key":"service.name","value":{"stringValue":"hodor"}},` +
+		`{"key":"hodor.gateway.id","value":{"intValue":"1"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"hodor"},"spans":[` +
+		`{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7","traceState":"a=1",` +
+		`"name":"GET /users","kind":2,"startTimeUnixNano":"1700000000000000005","endTimeUnixNano":"1700000001500000005",` +
+		`"attributes":[` +
+		`{"key":"http.method","value":{"stringValue":"GET"}},` +
+		`{"key":"http.status_code","value":{"intValue":"502"}},` +
+		`{"key":"hodor.retried","value":{"boolValue":true}},` +
+		`{"key":"hodor.ratio","value":{"doubleValue":0.25}},` +
+		`{"key":"hodor.other","value":{"stringValue":"[a]"}}],` +
+		`"status":{"code":2,"message":"bad gateway"}},` +
+		`{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"0102030405060708","parentSpanId":"00f067aa0ba902b7",` +
+		`"name":"proxy","kind":3,"startTimeUnixNano":"1700000000000000005","endTimeUnixNano":"1700000001000000005",` +
+		`"status":{}}]}]}]}`
+	if string(body) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, body)
+	}
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Headers carrying the trace context, as defined by the W3C Trace Context
+// recommendation.
+const (
+	TraceparentHeader = "Traceparent"
+	TracestateHeader  = "Tracestate"
+)
+
+// TraceID identifies a trace across all the services it goes through.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// SpanContext is the part of a span propagated to other services.
+// State is the vendor specific tracestate, passed on as it is.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	State   string
+}
+
+// IsValid reports whether neither of the ids is all zeroes.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent formats the span context as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Values of a future
+// version are accepted as long as they start like a version 00 one.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, false
+	}
+
+	var version, flags [1]byte
+	if !decodeHex(version[:], value[0:2]) || version[0] == 0xff {
+		return sc, false
+	}
+	if version[0] == 0 && len(value) != 55 {
+		return sc, false
+	}
+	if len(value) > 55 && value[55] != '-' {
+		return sc, false
+	}
+
+	if !decodeHex(sc.TraceID[:], value[3:35]) || !decodeHex(sc.SpanID[:], value[36:52]) || !decodeHex(flags[:], value[53:55]) {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Extract returns the span context propagated in the headers, if any.
+func Extract(header http.Header) (SpanContext, bool) {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return sc, false
+	}
+
+	state := strings.Join(header.Values(TracestateHeader), ",")
+	if len(state) <= 512 {
+		sc.State = state
+	}
+	return sc, true
+}
+
+// Inject sets the headers propagating the span context.
+func Inject(header http.Header, sc SpanContext) {
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.State != "" {
+		header.Set(TracestateHeader, sc.State)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// decodeHex decodes lower case hex only, as required by traceparent.
+func decodeHex(dst []byte, src string) bool {
+	if strings.ToLower(src) != src {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(src))
+	return err == nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		rand.Read(id[:])
+	}
+	return id
+}
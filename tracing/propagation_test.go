@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, false},
+		{"unknown flags", "00-" + traceID + "-" + spanID + "-03", true, true},
+		{"surrounding spaces", " 00-" + traceID + "-" + spanID + "-01 ", true, true},
+		{"future version", "01-" + traceID + "-" + spanID + "-01", true, true},
+		{"future version with more fields", "01-" + traceID + "-" + spanID + "-01-extra", true, true},
+		{"future version with longer flags", "01-" + traceID + "-" + spanID + "-01extra", false, false},
+		{"version 00 with more fields", "00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"invalid version", "ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"zero span id", "00-" + traceID + "-0000000000000000-01", false, false},
+		{"invalid flags", "00-" + traceID + "-" + spanID + "-0x", false, false},
+		{"invalid separator", "00_" + traceID + "-" + spanID + "-01", false, false},
+		{"short trace id", "00-" + traceID[2:] + "-" + spanID + "-01", false, false},
+		{"empty", "", false, false},
+	}
+
+	for _, test := range tests {
+		sc, ok := ParseTraceparent(test.value)
+		if ok != test.valid {
+			t.Errorf("%s :: expected valid %t, got %t", test.name, test.valid, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if sc.Sampled != test.sampled {
+			t.Errorf("%s :: expected sampled %t, got %t", test.name, test.sampled, sc.Sampled)
+		}
+		flags := "-00"
+		if test.sampled {
+			flags = "-01"
+		}
+		if want := "00-" + traceID + "-" + spanID + flags; sc.Traceparent() != want {
+			t.Errorf("%s :: expected traceparent '%s', got '%s'", test.name, want, sc.Traceparent())
+		}
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Add(TracestateHeader, "a=1")
+	header.Add(TracestateHeader, "b=2")
+
+	sc, ok := Extract(header)
+	if !ok {
+		t.Fatal("expected the span context to be extracted")
+	}
+	if sc.State != "a=1,b=2" {
+		t.Errorf("expected the tracestate values to be joined, got '%s'", sc.State)
+	}
+
+	out := http.Header{TracestateHeader: {"stale"}}
+	Inject(out, sc)
+	if out.Get(TraceparentHeader) != header.Get(TraceparentHeader) || out.Get(TracestateHeader) != "a=1,b=2" {
+		t.Errorf("expected the span context to be injected, got %v", out)
+	}
+
+	sc.State = ""
+	Inject(out, sc)
+	if _, ok := out[TracestateHeader]; ok {
+		t.Errorf("expected the stale tracestate to be removed, got %v", out)
+	}
+}
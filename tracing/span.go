@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"net/http"
+	"time"
+)
+
+// SpanKind describes the relationship of a span to its parent and
+// children, with the values of the OTLP enum.
+type SpanKind int
+
+// Span kinds
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// statusError is the OTLP status code of failed spans
+const statusError = 2
+
+// attribute is a key value pair describing a span. Values are strings,
+// integers, floats or booleans.
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span is a single operation within a trace. Spans that are not sampled
+// are still created, so that their context can be propagated, but record
+// nothing and are not exported. All methods of Span are safe to call on
+// a nil Span, which is what is returned when tracing is disabled. A span
+// must only be used by the goroutine that started it.
+type Span struct {
+	Name     string
+	Kind     SpanKind
+	Context  SpanContext
+	ParentID SpanID
+
+	tracer        *Tracer
+	start         time.Time
+	end           time.Time
+	attributes    []attribute
+	statusCode    int
+	statusMessage string
+}
+
+// SetAttribute records an attribute of the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.IsRecording() {
+		return
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed, with a description of the error.
+func (s *Span) SetError(message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.statusCode = statusError
+	s.statusMessage = message
+}
+
+// IsRecording reports whether the span is sampled and has not ended yet.
+func (s *Span) IsRecording() bool {
+	return s != nil && s.Context.Sampled && s.end.IsZero()
+}
+
+// End ends the span and queues it for export. Calls after the first one
+// are ignored.
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.exporter.export(s)
+}
+
+// Inject sets the headers propagating the context of the span to the
+// service it calls.
+func (s *Span) Inject(header http.Header) {
+	if s == nil {
+		return
+	}
+	Inject(header, s.Context)
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/saidmithilesh/hodor/config"
+)
+
+type contextKey int
+
+const spanContextKey contextKey = iota
+
+// Tracer starts the spans of the gateway and exports the sampled ones to
+// the OTLP/HTTP endpoint of a collector. All methods of Tracer are safe to
+// call on a nil Tracer, they then return nil spans.
+type Tracer struct {
+	exporter *exporter
+}
+
+// New creates a Tracer exporting spans as configured for the (already
+// optimised) gateway config.
+func New(gc *config.GatewayConfig) *Tracer {
+	resource := []attribute{
+		{key: "service.name", value: gc.Tracing.ServiceName},
+		{key: "service.instance.id", value: strconv.FormatUint(uint64(gc.InstanceID), 10)},
+		{key: "hodor.gateway.id", value: int64(gc.ID)},
+		{key: "hodor.gateway.name", value: gc.Name},
+	}
+	return &Tracer{exporter: newExporter(&gc.Tracing, resource)}
+}
+
+// StartServer starts the span of a request received by the gateway, as a
+// child of the span propagated in the request headers if any. Otherwise
+// the span starts a new trace, recorded with the probability of ratio.
+func (t *Tracer) StartServer(ctx context.Context, name string, header http.Header, ratio float64) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{Name: name, Kind: SpanKindServer, tracer: t, start: time.Now()}
+	if parent, ok := Extract(header); ok {
+		span.Context = parent
+		span.ParentID = parent.SpanID
+	} else {
+		span.Context.TraceID = newTraceID()
+		span.Context.Sampled = sample(span.Context.TraceID, ratio)
+	}
+	span.Context.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanContextKey, span), span
+}
+
+// Start starts a span as a child of the span attached to the context. It
+// returns a nil span if there is none.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if t == nil || parent == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		Name:     name,
+		Kind:     kind,
+		Context:  parent.Context,
+		ParentID: parent.Context.SpanID,
+		tracer:   t,
+		start:    time.Now(),
+	}
+	span.Context.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanContextKey, span), span
+}
+
+// Sync waits for the spans ended so far to be exported, for at most the
+// configured timeout.
+func (t *Tracer) Sync() error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.sync()
+}
+
+// SpanFromContext returns the span attached to the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey).(*Span)
+	return span
+}
+
+// sample decides whether a new trace is recorded. The decision is derived
+// from the trace id, the same way as OpenTelemetry's TraceIDRatioBased
+// sampler, so that it is consistent across services.
+func sample(id TraceID, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	return binary.BigEndian.Uint64(id[8:])>>1 < uint64(ratio*(1<<63))
+}
@@ -0,0 +1,36 @@
+package tracing
+
+import "testing"
+
+func TestSample(t *testing.T) {
+	traceID := func(low byte, rest byte) TraceID {
+		var id TraceID
+		id[0] = 0xff
+		id[8] = low
+		for i := 9; i < len(id); i++ {
+			id[i] = rest
+		}
+		return id
+	}
+
+	tests := []struct {
+		name  string
+		id    TraceID
+		ratio float64
+		want  bool
+	}{
+		{"always", traceID(0xff, 0xff), 1, true},
+		{"above one", traceID(0xff, 0xff), 1.5, true},
+		{"never", traceID(0x00, 0x00), 0, false},
+		{"lowest id", traceID(0x00, 0x00), 0.001, true},
+		{"below the bound", traceID(0x7f, 0xff), 0.5, true},
+		{"at the bound", traceID(0x80, 0x00), 0.5, false},
+		{"highest id", traceID(0xff, 0xff), 0.999, false},
+	}
+
+	for _, test := range tests {
+		if got := sample(test.id, test.ratio); got != test.want {
+			t.Errorf("%s :: expected %t for a ratio of %g, got %t", test.name, test.want, test.ratio, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/saidmithilesh/hodor/config"
+	"github.com/saidmithilesh/hodor/gateway"
+)
+
+// validationReport is the output of the validate command in the JSON
+// format.
+type validationReport struct {
+	Valid  bool                      `json:"valid"`
+	File   string                    `json:"file"`
+	Errors []*config.ValidationError `json:"errors"`
+}
+
+// validate loads the config file and reports the rules it breaks, exiting
+// with a non-zero status if it breaks any.
+func validate(args []string) {
+	flags, configFilePath := newFlagSet("validate")
+	format := flags.String("format", "text", "Format of the report, text or json")
+	flags.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Invalid value '%s' provided for -format. Allowed values are text and json\n", *format)
+		os.Exit(2)
+	}
+
+	_, err := config.Load(*configFilePath, config.WithMiddlewareLookup(gateway.MiddlewareRegistered))
+	errs, ok := err.(config.ValidationErrors)
+	if err != nil && !ok {
+		log.Fatalf("Error while loading the config file :: %s", err)
+	}
+
+	if *format == "json" {
+		report := validationReport{
+			Valid:  len(errs) == 0,
+			File:   *configFilePath,
+			Errors: errs,
+		}
+		if report.Errors == nil {
+			report.Errors = []*config.ValidationError{}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		if !report.Valid {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(errs) > 0 {
+		reportConfigError(errs)
+	}
+	log.Printf("Config file '%s' is valid", *configFilePath)
+}